@@ -2,132 +2,538 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cloudwego/eino/compose"
 )
 
 type SubtitleAgent struct {
 	chain compose.Runnable[AgentInput, AgentOutput]
+	// model 用于确定默认的每组 token 上限
+	model string
+	// reviewModel 记录在失败分组记录中，retry-failed 重新审校时使用同一个模型
+	reviewModel string
 }
 
 type AgentInput struct {
 	SubtitlePath string
 	OutputPath   string
 	OutputFormat string
+	SourceLang   string
+	TargetLangs  []string
+	// CombineOutput 为 true 时所有目标语言写入同一个输出文件，否则每种语言单独输出
+	CombineOutput bool
+	// ASSStyle 输出 ASS 格式时使用的字体样式
+	ASSStyle ASSStyle
+	// ASSMode 输入为 ASS 时的输出方式：ASSModeRebuild（默认）、ASSModeInline 或 ASSModeCompanion
+	ASSMode string
+	// Glossaries 术语表文件，格式为 path 或 lang=path
+	Glossaries []string
+	// Resume 为 true 时从输出文件旁的断点日志恢复，只翻译未完成的分组
+	Resume bool
+	// ExtractTerms 为 true 时翻译前先从整部字幕中提取术语，保存为输出文件旁的术语表并在翻译时使用
+	ExtractTerms bool
+	// Review 为 true 时翻译完成后审校译文，并在输出文件旁写入审校报告
+	Review bool
+	// ReviewFix 为 true 时自动采用审校建议的译文，否则只写入报告
+	ReviewFix bool
+	// Grouping 字幕分组参数，零值表示使用 DefaultGroupOptions，MaxTokens 为 0 时按模型确定
+	Grouping GroupOptions
+	// OnEvent 接收运行过程中的事件，可以为 nil
+	OnEvent EventFunc
+	// MaxFallbackRatio 以原文代替译文的字幕所占比例超过该值时运行失败，不写入输出文件
+	MaxFallbackRatio float64
 }
 
 type AgentOutput struct {
-	Success  bool
-	Message  string
-	Subtitle *Subtitle
+	Success     bool
+	Message     string
+	Subtitle    *Subtitle
+	OutputPaths []string
+	Report      *RunReport
 }
 
-func NewSubtitleAgent(ctx context.Context, apiKey string, baseURL string, modelName string) (*SubtitleAgent, error) {
-	log.Printf("[Agent] 初始化字幕翻译 Agent，模型: %s", modelName)
+type outputTarget struct {
+	Path  string
+	Langs []string
+}
+
+// agentState 在 Chain 的各个步骤之间传递输入参数、字幕、翻译器和运行报告
+type agentState struct {
+	Input      AgentInput
+	Subtitle   *Subtitle
+	Translator *Translator
+	Report     *RunReport
+}
+
+func NewSubtitleAgent(ctx context.Context, config *TranslatorConfig) (*SubtitleAgent, error) {
+	logAgent.Info("初始化字幕翻译 Agent", "initializing subtitle translation agent", "model", config.Model)
 
 	chain := compose.NewChain[AgentInput, AgentOutput]()
 
-	chain.AppendLambda(compose.InvokableLambda(func(ctx context.Context, input AgentInput) (*Subtitle, error) {
-		log.Printf("[Agent] 步骤1: 解析字幕文件: %s", input.SubtitlePath)
-		sub, err := ParseSubtitle(input.SubtitlePath)
+	chain.AppendLambda(compose.InvokableLambda(func(ctx context.Context, input AgentInput) (*agentState, error) {
+		logAgent.Info("步骤1: 解析字幕文件", "step 1: parsing subtitle file", "input", input.SubtitlePath)
+		sub, err := parseInputSubtitle(input)
 		if err != nil {
-			log.Printf("[Agent] 解析字幕失败: %v", err)
+			logAgent.Error("解析字幕失败", "failed to parse subtitle", "err", err)
 			return nil, err
 		}
-		log.Printf("[Agent] 解析完成，共 %d 条字幕", len(sub.Items))
-		return sub, nil
+		sub.SourceLang = input.SourceLang
+		logAgent.Info("解析完成", "subtitle parsed", "cues", len(sub.Items))
+		emitEvent(input, Event{Type: EventParseDone, Cues: len(sub.Items)})
+		report := &RunReport{
+			Input:       input.SubtitlePath,
+			Model:       config.Model,
+			SourceLang:  input.SourceLang,
+			TargetLangs: input.TargetLangs,
+			StartedAt:   time.Now(),
+			Cues:        len(sub.Items),
+		}
+		return &agentState{Input: input, Subtitle: sub, Report: report}, nil
 	}))
 
-	chain.AppendLambda(compose.InvokableLambda(func(ctx context.Context, sub *Subtitle) (*Subtitle, error) {
-		log.Printf("[Agent] 步骤2: 开始翻译 %d 条字幕", len(sub.Items))
+	chain.AppendLambda(compose.InvokableLambda(func(ctx context.Context, state *agentState) (*agentState, error) {
+		logAgent.Info("步骤2: 准备术语表", "step 2: preparing glossaries")
 
-		translator, err := NewTranslator(ctx, apiKey, baseURL, modelName)
+		translator, err := NewTranslator(ctx, config)
 		if err != nil {
-			log.Printf("[Agent] 创建翻译器失败: %v", err)
+			logAgent.Error("创建翻译器失败", "failed to create translator", "err", err)
 			return nil, err
 		}
+		translator.events = func(e Event) { emitEvent(state.Input, e) }
+		state.Translator = translator
 
-		err = translator.SummarizeContext(ctx, inputFile, sub.Items)
-		if err != nil {
-			log.Printf("[Agent] 总结背景信息失败: %v", err)
+		for _, spec := range state.Input.Glossaries {
+			lang, path := parseGlossarySpec(spec)
+			glossary, err := LoadGlossary(path)
+			if err != nil {
+				logAgent.Error("加载术语表失败", "failed to load glossary", "path", path, "err", err)
+				return nil, err
+			}
+			logAgent.Info("已加载术语表", "glossary loaded", "path", path, "terms", len(glossary.Entries))
+			translator.AddGlossary(lang, glossary)
 		}
+		return state, nil
+	}))
+
+	chain.AppendLambda(compose.InvokableLambda(func(ctx context.Context, state *agentState) (*agentState, error) {
+		if !state.Input.ExtractTerms {
+			return state, nil
+		}
+		logAgent.Info("步骤3: 提取术语", "step 3: extracting terms")
 
-		groups := GroupSubtitlesByTime(sub.Items, 3.0)
-		log.Printf("[Agent] 将字幕分为 %d 个组进行翻译", len(groups))
+		translator := state.Translator
+		for _, targetLang := range state.Input.TargetLangs {
+			// 已有的术语表可能经过手工修改，直接使用而不重新提取；需要重新提取时删除该文件即可
+			path := termsGlossaryPath(state.Input.OutputPath, targetLang)
+			if glossary, err := LoadGlossary(path); err == nil {
+				logAgent.Info("使用已有的术语表", "using existing glossary", "path", path, "terms", len(glossary.Entries))
+				translator.AddGlossary(targetLang, glossary)
+				continue
+			} else if !errors.Is(err, os.ErrNotExist) {
+				logAgent.Error("加载术语表失败", "failed to load glossary", "path", path, "err", err)
+				return nil, err
+			}
 
-		translatedMap, err := translator.TranslateGroups(ctx, groups)
+			glossary, err := translator.ExtractTerms(ctx, state.Subtitle.Items, state.Input.SourceLang, targetLang)
+			if err != nil {
+				logAgent.Error("提取术语失败", "failed to extract terms", "err", err)
+				return nil, err
+			}
+			if err := SaveGlossary(path, glossary); err != nil {
+				logAgent.Error("保存术语表失败", "failed to save glossary", "path", path, "err", err)
+				return nil, err
+			}
+			logAgent.Info("术语表已保存", "glossary saved", "path", path)
+			translator.AddGlossary(targetLang, glossary)
+		}
+		return state, nil
+	}))
+
+	chain.AppendLambda(compose.InvokableLambda(func(ctx context.Context, state *agentState) (*agentState, error) {
+		sub := state.Subtitle
+		translator := state.Translator
+		logAgent.Info("步骤4: 开始翻译", "step 4: translating", "cues", len(sub.Items), "source_lang", state.Input.SourceLang, "target_langs", strings.Join(state.Input.TargetLangs, ","))
+
+		checkpoint, err := OpenCheckpoint(checkpointPath(state.Input.OutputPath), state.Input.SubtitlePath, state.Input.Resume)
 		if err != nil {
-			log.Printf("[Agent] 分组翻译失败: %v", err)
+			logAgent.Error("打开断点日志失败", "failed to open checkpoint", "err", err)
 			return nil, err
 		}
+		defer checkpoint.Close()
+
+		if summary, ok := checkpoint.Summary(); ok {
+			logAgent.Info("从断点日志恢复背景信息", "restored background information from checkpoint")
+			translator.context = summary
+		} else {
+			err = translator.SummarizeContext(ctx, state.Input.SubtitlePath, sub.Items)
+			var fatal *FatalAPIError
+			if errors.As(err, &fatal) {
+				// 所有模型都拒绝请求（例如 API Key 无效）时所有分组同样会失败，不再继续
+				logAgent.Error("总结背景信息失败", "failed to summarize background information", "err", err)
+				return nil, err
+			} else if err != nil {
+				logAgent.Warn("总结背景信息失败", "failed to summarize background information", "err", err)
+			} else if err := checkpoint.RecordSummary(translator.context); err != nil {
+				logAgent.Warn("写入断点日志失败", "failed to write checkpoint", "err", err)
+			}
+		}
+		if translator.context != "" {
+			emitEvent(state.Input, Event{Type: EventSummaryDone, Summary: translator.context})
+		}
+
+		groups := GroupSubtitles(sub.Items, state.Input.Grouping)
+		logAgent.Info("字幕分组完成", "subtitle grouped", "groups", len(groups))
+
+		var doneGroups, doneCues atomic.Int64
+		totalGroups := len(groups) * len(state.Input.TargetLangs)
+		totalCues := len(sub.Items) * len(state.Input.TargetLangs)
+		groupDone := func(report *GroupReport) {
+			groupID := report.Group
+			emitEvent(state.Input, Event{
+				Type:         EventGroupDone,
+				TargetLang:   report.TargetLang,
+				Group:        &groupID,
+				Cues:         len(report.Cues),
+				Model:        report.Model,
+				Method:       report.Method,
+				FallbackCues: len(report.FallbackCues),
+				Error:        report.Error,
+				DoneGroups:   int(doneGroups.Add(1)),
+				TotalGroups:  totalGroups,
+				DoneCues:     int(doneCues.Add(int64(len(report.Cues)))),
+				TotalCues:    totalCues,
+				Tokens:       translator.usage.Tokens(),
+			})
+		}
+
+		for _, targetLang := range state.Input.TargetLangs {
+			logAgent.Info("翻译目标语言", "translating target language", "target_lang", targetLang)
 
-		for i, item := range sub.Items {
-			if trans, ok := translatedMap[i]; ok {
-				item.Chinese = trans
+			var pending []SubtitleGroup
+			restored := make(map[int][]string)
+			for _, group := range groups {
+				translations, ok := checkpoint.Lookup(targetLang, group)
+				if !ok {
+					if prev, ok := restored[group.ID-1]; ok {
+						group.SetPrevTranslations(prev)
+					}
+					pending = append(pending, group)
+					continue
+				}
+				restored[group.ID] = translations
+				for i, idx := range group.Indices {
+					sub.Items[idx].SetTranslation(targetLang, translations[i])
+				}
+				report := newGroupReport(group)
+				report.TargetLang = targetLang
+				report.Method = GroupMethodCheckpoint
+				state.Report.AddGroup(report)
+				groupDone(report)
+			}
+			if len(pending) < len(groups) {
+				logAgent.Info("从断点日志恢复分组", "restored groups from checkpoint", "restored", len(groups)-len(pending), "pending", len(pending))
+			}
+
+			var failedMu sync.Mutex
+			var failedErrs []string
+			onGroupDone := func(group SubtitleGroup, translations []string, report *GroupReport) {
+				state.Report.AddGroup(report)
+				groupDone(report)
+				if report.Error != "" {
+					failedMu.Lock()
+					failedErrs = append(failedErrs, report.Error)
+					failedMu.Unlock()
+				}
+				// 没有通过校验的译文（缺少条目或没有使用术语表）不写入断点日志，续传时重新翻译
+				if !report.valid || len(translations) != len(group.Indices) {
+					return
+				}
+				if err := checkpoint.RecordGroup(targetLang, group, translations); err != nil {
+					logAgent.Warn("写入断点日志失败", "failed to write checkpoint", "err", err)
+				}
+			}
+
+			translatedMap, err := translator.TranslateGroups(ctx, pending, state.Input.SourceLang, targetLang, onGroupDone)
+			if err != nil {
+				logAgent.Error("分组翻译失败", "failed to translate groups", "err", err)
+				return nil, err
+			}
+			// 包括从断点日志恢复的在内，所有分组都失败时通常不是个别分组的问题（例如模型不可用），直接报错；
+			// 只要有分组得到了译文，失败的分组就记录下来，留给 retry-failed 重新翻译
+			if len(groups) > 0 && len(failedErrs) == len(groups) {
+				logAgent.Error("所有分组都翻译失败", "all groups failed", "target_lang", targetLang, "groups", len(groups))
+				return nil, fmt.Errorf("all %d groups failed for %s: %s", len(groups), targetLang, failedErrs[0])
+			}
+
+			for i, item := range sub.Items {
+				if trans, ok := translatedMap[i]; ok {
+					item.SetTranslation(targetLang, trans)
+				}
 			}
 		}
 
-		log.Printf("[Agent] 翻译完成")
-		return sub, nil
+		logAgent.Info("翻译完成", "translation finished")
+		return state, nil
 	}))
 
-	chain.AppendLambda(compose.InvokableLambda(func(ctx context.Context, sub *Subtitle) (AgentOutput, error) {
-		log.Printf("[Agent] 步骤3: 准备生成输出")
+	chain.AppendLambda(compose.InvokableLambda(func(ctx context.Context, state *agentState) (*agentState, error) {
+		if !state.Input.Review {
+			return state, nil
+		}
+		logAgent.Info("步骤5: 审校译文", "step 5: reviewing translations")
+
+		reviewer, err := NewReviewer(ctx, config, state.Translator)
+		if err != nil {
+			logAgent.Error("创建审校器失败", "failed to create reviewer", "err", err)
+			return nil, err
+		}
+
+		for _, targetLang := range state.Input.TargetLangs {
+			report, err := reviewer.ReviewTranslations(ctx, state.Subtitle.Items, state.Input.SourceLang, targetLang, state.Input.ReviewFix)
+			if err != nil {
+				logAgent.Error("审校失败", "review failed", "err", err)
+				return nil, err
+			}
+
+			path := reviewReportPath(state.Input.OutputPath, targetLang)
+			if err := SaveReviewReport(path, report); err != nil {
+				logAgent.Error("保存审校报告失败", "failed to save review report", "path", path, "err", err)
+				return nil, err
+			}
+			logAgent.Info("审校完成，报告已保存", "review finished, report saved", "issues", len(report.Issues), "path", path)
+		}
+		return state, nil
+	}))
+
+	chain.AppendLambda(compose.InvokableLambda(func(ctx context.Context, state *agentState) (AgentOutput, error) {
+		logAgent.Info("步骤6: 准备生成输出", "step 6: preparing output")
+		state.Report.Usage = state.Translator.Usage(config.Prices)
 		return AgentOutput{
 			Success:  true,
 			Message:  "subtitle translated successfully",
-			Subtitle: sub,
+			Subtitle: state.Subtitle,
+			Report:   state.Report,
 		}, nil
 	}))
 
 	compiledChain, err := chain.Compile(ctx)
 	if err != nil {
-		log.Printf("[Agent] 编译 Chain 失败: %v", err)
+		logAgent.Error("编译 Chain 失败", "failed to compile chain", "err", err)
 		return nil, fmt.Errorf("failed to compile chain: %w", err)
 	}
 
-	log.Printf("[Agent] Agent 初始化完成")
+	logAgent.Info("Agent 初始化完成", "agent initialized")
 	return &SubtitleAgent{
-		chain: compiledChain,
+		chain:       compiledChain,
+		model:       config.Model,
+		reviewModel: config.ReviewModel,
 	}, nil
 }
 
-func (a *SubtitleAgent) Run(ctx context.Context, input AgentInput) (AgentOutput, error) {
-	log.Printf("[Agent] 开始运行 Agent，输入: %+v", input)
+func (a *SubtitleAgent) Run(ctx context.Context, input AgentInput) (output AgentOutput, err error) {
+	logAgent.Info("开始运行 Agent", "running agent", "input", input.SubtitlePath, "output", input.OutputPath, "format", input.OutputFormat,
+		"source_lang", input.SourceLang, "target_langs", strings.Join(input.TargetLangs, ","), "resume", input.Resume)
+
+	defer func() {
+		e := Event{Type: EventFinished, Success: output.Success, Message: output.Message, Outputs: output.OutputPaths}
+		if err != nil && e.Message == "" {
+			e.Message = err.Error()
+		}
+		if output.Report != nil {
+			e.Usage = output.Report.Usage
+		}
+		emitEvent(input, e)
+	}()
 
-	output, err := a.chain.Invoke(ctx, input)
+	// 分组参数在运行前按模型确定，失败分组记录中保存确定后的值，retry-failed 换用其他模型时分组不变
+	input.Grouping = groupOptions(input, a.model)
+	output, err = a.chain.Invoke(ctx, input)
 	if err != nil {
-		log.Printf("[Agent] 运行失败: %v", err)
+		logAgent.Error("运行失败", "run failed", "err", err)
 		return output, err
 	}
 
+	var failedGroups []*GroupReport
+	if output.Report != nil {
+		report := output.Report
+		report.Finish()
+		failedGroups = report.FailedGroups()
+		if report.FallbackCues > 0 {
+			logAgent.Warn("部分字幕没有得到译文，使用原文代替，详见运行报告", "some cues were not translated and fell back to the source text, see the run report", "fallback_cues", report.FallbackCues)
+		}
+		if report.FallbackRatio() > input.MaxFallbackRatio {
+			output.Success = false
+			output.Message = fmt.Sprintf("%d of %d cues fell back to the source text (%.1f%% > %.1f%%), output not written; rerun with --resume to retry the failed groups",
+				report.FallbackCues, report.Cues*len(report.TargetLangs), report.FallbackRatio()*100, input.MaxFallbackRatio*100)
+		}
+	}
+
 	if output.Success && output.Subtitle != nil {
-		log.Printf("[Agent] 步骤4: 生成 %s 格式输出", input.OutputFormat)
-		var content string
-		switch input.OutputFormat {
-		case "srt", "SRT":
-			content = output.Subtitle.GenerateSRT()
-		case "ass", "ASS":
-			content = output.Subtitle.GenerateASS()
-		default:
-			content = output.Subtitle.GenerateSRT()
-		}
-
-		log.Printf("[Agent] 保存输出到: %s", input.OutputPath)
-		err := saveToFile(input.OutputPath, content)
-		if err != nil {
-			log.Printf("[Agent] 保存输出失败: %v", err)
-			return AgentOutput{
-				Success: false,
-				Message: fmt.Sprintf("failed to save output: %v", err),
-			}, err
+		logAgent.Info("步骤7: 生成输出", "step 7: writing output", "format", input.OutputFormat)
+
+		for _, file := range outputTargets(input) {
+			content, err := renderSubtitle(output.Subtitle, input, input.OutputFormat, file.Langs)
+			if err != nil {
+				logAgent.Error("生成输出失败", "failed to generate output", "err", err)
+				return AgentOutput{
+					Success: false,
+					Message: fmt.Sprintf("failed to generate output: %v", err),
+				}, err
+			}
+
+			logAgent.Info("保存输出", "saving output", "path", file.Path)
+			if err := saveToFile(file.Path, content); err != nil {
+				logAgent.Error("保存输出失败", "failed to save output", "path", file.Path, "err", err)
+				return AgentOutput{
+					Success: false,
+					Message: fmt.Sprintf("failed to save output: %v", err),
+				}, err
+			}
+			output.OutputPaths = append(output.OutputPaths, file.Path)
 		}
 
-		output.Message = fmt.Sprintf("subtitle translated successfully, saved to %s", input.OutputPath)
-		log.Printf("[Agent] 运行成功: %s", output.Message)
+		// 有失败的分组时保留断点日志，retry-failed 从中恢复已完成的分组
+		if len(failedGroups) == 0 {
+			if err := os.Remove(checkpointPath(input.OutputPath)); err != nil && !os.IsNotExist(err) {
+				logAgent.Warn("删除断点日志失败", "failed to remove checkpoint", "err", err)
+			}
+		}
+
+		output.Message = fmt.Sprintf("subtitle translated successfully, saved to %s", strings.Join(output.OutputPaths, ", "))
+		logAgent.Info("运行成功", "run succeeded", "outputs", strings.Join(output.OutputPaths, ","))
+	}
+
+	if output.Report != nil {
+		path := failedGroupsPath(input)
+		if len(failedGroups) > 0 {
+			if err := SaveFailedGroups(path, newFailedGroups(input, a.reviewModel, failedGroups)); err != nil {
+				logAgent.Warn("保存失败分组记录失败", "failed to save failed groups", "path", path, "err", err)
+			} else {
+				logAgent.Warn("部分分组翻译失败，可用 retry-failed 重新翻译", "some groups failed, retry them with retry-failed", "groups", len(failedGroups), "path", path)
+				output.Message += fmt.Sprintf("; %d groups failed, run `subai retry-failed %s` to retry them", len(failedGroups), input.OutputPath)
+			}
+		} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logAgent.Warn("删除失败分组记录失败", "failed to remove failed groups", "path", path, "err", err)
+		}
+	}
+
+	if output.Report != nil {
+		output.Report.Outputs = append([]string{}, output.OutputPaths...)
+		path := runReportPath(input)
+		if err := SaveRunReport(path, output.Report); err != nil {
+			logAgent.Warn("保存运行报告失败", "failed to save run report", "path", path, "err", err)
+		} else {
+			logAgent.Info("运行报告已保存", "run report saved", "path", path)
+		}
 	}
 
 	return output, nil
 }
+
+// emitEvent 补全事件的时间和输入文件后交给 input.OnEvent
+func emitEvent(input AgentInput, e Event) {
+	if input.OnEvent == nil {
+		return
+	}
+	e.Time = time.Now()
+	e.Input = input.SubtitlePath
+	input.OnEvent(e)
+}
+
+// parseInputSubtitle 按 ASS 模式解析输入字幕：保留原始脚本的模式需要 ASS/SSA 输入
+func parseInputSubtitle(input AgentInput) (*Subtitle, error) {
+	switch input.ASSMode {
+	case "", ASSModeRebuild, ASSModeInline, ASSModeCompanion:
+	default:
+		return nil, fmt.Errorf("unknown ASS mode %q", input.ASSMode)
+	}
+	if preservesASS(input.ASSMode) {
+		if !isASSFile(input.SubtitlePath) {
+			return nil, fmt.Errorf("ASS mode %q requires an ASS/SSA input file", input.ASSMode)
+		}
+		return ParseASSSubtitle(input.SubtitlePath)
+	}
+	return ParseSubtitle(input.SubtitlePath)
+}
+
+// groupOptions 返回输入指定的分组参数，未指定时使用 DefaultGroupOptions，MaxTokens 为 0 时使用 model 的默认值
+func groupOptions(input AgentInput, model string) GroupOptions {
+	opts := input.Grouping
+	if opts == (GroupOptions{}) {
+		opts = DefaultGroupOptions
+	}
+	if opts.MaxTokens == 0 {
+		opts.MaxTokens = modelGroupMaxTokens(model)
+	}
+	return opts
+}
+
+// outputTargets 返回本次运行要写入的输出文件及每个文件包含的目标语言
+func outputTargets(input AgentInput) []outputTarget {
+	if input.CombineOutput || len(input.TargetLangs) <= 1 {
+		path := strings.ReplaceAll(input.OutputPath, langPlaceholder, strings.Join(input.TargetLangs, "+"))
+		return []outputTarget{{Path: path, Langs: input.TargetLangs}}
+	}
+
+	files := make([]outputTarget, 0, len(input.TargetLangs))
+	for _, lang := range input.TargetLangs {
+		files = append(files, outputTarget{Path: outputPathForLang(input.OutputPath, lang), Langs: []string{lang}})
+	}
+	return files
+}
+
+// renderSubtitle 按输出格式生成包含 langs 译文的字幕内容，未知格式按 SRT 输出
+func renderSubtitle(sub *Subtitle, input AgentInput, format string, langs []string) (string, error) {
+	switch format {
+	case "ass", "ASS":
+		if preservesASS(input.ASSMode) {
+			return sub.GenerateASSPreserved(input.ASSMode, langs...)
+		}
+		return sub.GenerateASS(input.ASSStyle, langs...), nil
+	case "vtt", "VTT":
+		return sub.GenerateVTT(langs...)
+	default:
+		return sub.GenerateSRT(langs...), nil
+	}
+}
+
+// preservesASS 判断 ASS 模式是否需要保留原始脚本
+func preservesASS(mode string) bool {
+	return mode == ASSModeInline || mode == ASSModeCompanion
+}
+
+func isASSFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ass", ".ssa":
+		return true
+	default:
+		return false
+	}
+}
+
+// langPlaceholder 可以出现在输出路径中，会被替换为目标语言代码
+const langPlaceholder = "{lang}"
+
+// sidecarPathForLang 返回某个目标语言的输出文件旁的附属文件路径，suffix 替换输出文件的扩展名
+func sidecarPathForLang(outputPath string, lang string, suffix string) string {
+	path := outputPathForLang(outputPath, lang)
+	return strings.TrimSuffix(path, filepath.Ext(path)) + suffix
+}
+
+// outputPathForLang 返回某个目标语言的输出路径：路径中包含 {lang} 时直接替换，
+// 否则在扩展名前插入语言代码，例如 movie.srt -> movie.ja.srt
+func outputPathForLang(path string, lang string) string {
+	if strings.Contains(path, langPlaceholder) {
+		return strings.ReplaceAll(path, langPlaceholder, lang)
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + lang + ext
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testAgentSRT 的两条字幕在同一个分组中，"Bob" 出现两次，会被提取为术语
+const testAgentSRT = `1
+00:00:01,000 --> 00:00:02,000
+Hello, Bob.
+
+2
+00:00:02,500 --> 00:00:04,000
+Bob?
+`
+
+// testAgentResponse 同时带有内容和 submit_translation 工具调用：背景总结、术语提取和审校读取内容
+// （内容中的一项既是术语也是审校问题），翻译分组读取工具调用
+const testAgentResponse = `{
+	"id": "chatcmpl-0",
+	"object": "chat.completion",
+	"created": 0,
+	"model": "m",
+	"choices": [{
+		"index": 0,
+		"message": {
+			"role": "assistant",
+			"content": "[{\"source\": \"Bob\", \"target\": \"鲍勃\", \"note\": \"name\", \"index\": 2, \"type\": \"tone\", \"problem\": \"too flat\", \"suggestion\": \"鲍勃！\"}]",
+			"tool_calls": [{"id": "call_0", "type": "function", "function": {"name": "submit_translation", "arguments": "{\"translations\": [\"你好，鲍勃。\", \"鲍勃？\"]}"}}]
+		},
+		"finish_reason": "tool_calls"
+	}],
+	"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+}`
+
+// newTestAgentInput 把字幕 srt 写入临时目录，返回把它译成中文的运行参数
+func newTestAgentInput(t *testing.T, srt string) AgentInput {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "movie.srt")
+	if err := os.WriteFile(path, []byte(srt), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return AgentInput{
+		SubtitlePath: path,
+		OutputPath:   filepath.Join(dir, "movie.zh.srt"),
+		OutputFormat: "srt",
+		SourceLang:   "en",
+		TargetLangs:  []string{"zh"},
+	}
+}
+
+func TestSubtitleAgentExtractTerms(t *testing.T) {
+	server, _ := newStubServer(t, http.StatusOK, testAgentResponse)
+	agent, err := NewSubtitleAgent(context.Background(), &TranslatorConfig{Provider: ProviderOpenAI, APIKey: "sk-test", BaseURL: server.URL, Model: "m"})
+	if err != nil {
+		t.Fatalf("NewSubtitleAgent: %v", err)
+	}
+
+	input := newTestAgentInput(t, testAgentSRT)
+	input.ExtractTerms = true
+	output, err := agent.Run(context.Background(), input)
+	if err != nil || !output.Success {
+		t.Fatalf("Run = %+v, %v", output, err)
+	}
+
+	// 提取的术语保存在输出文件旁，翻译时使用
+	glossary, err := LoadGlossary(termsGlossaryPath(input.OutputPath, "zh"))
+	if err != nil {
+		t.Fatalf("LoadGlossary: %v", err)
+	}
+	if entry, ok := glossary.Lookup("Bob"); !ok || entry.Target != "鲍勃" {
+		t.Errorf("glossary = %+v, want Bob => 鲍勃", glossary.Entries)
+	}
+	data, err := os.ReadFile(input.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "你好，鲍勃。\nHello, Bob.") {
+		t.Errorf("output:\n%s", data)
+	}
+
+	// 不提取术语时跳过这一步，不写入术语表
+	input = newTestAgentInput(t, testAgentSRT)
+	if output, err := agent.Run(context.Background(), input); err != nil || !output.Success {
+		t.Fatalf("Run without term extraction = %+v, %v", output, err)
+	}
+	if _, err := os.Stat(termsGlossaryPath(input.OutputPath, "zh")); !os.IsNotExist(err) {
+		t.Errorf("glossary written without term extraction: %v", err)
+	}
+}
+
+func TestSubtitleAgentCacheHit(t *testing.T) {
+	server, got := newStubServer(t, http.StatusOK, testAgentResponse)
+	cache, err := NewTranslationCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, err := NewSubtitleAgent(context.Background(), &TranslatorConfig{Provider: ProviderOpenAI, APIKey: "sk-test", BaseURL: server.URL, Model: "m", Cache: cache})
+	if err != nil {
+		t.Fatalf("NewSubtitleAgent: %v", err)
+	}
+	if output, err := agent.Run(context.Background(), newTestAgentInput(t, testAgentSRT)); err != nil || !output.Success {
+		t.Fatalf("first Run = %+v, %v", output, err)
+	}
+
+	// 同一个文件再次翻译时背景总结和分组都命中缓存，不再请求模型
+	*got = stubRequest{}
+	input := newTestAgentInput(t, testAgentSRT)
+	output, err := agent.Run(context.Background(), input)
+	if err != nil || !output.Success {
+		t.Fatalf("second Run = %+v, %v", output, err)
+	}
+	if got.Path != "" {
+		t.Errorf("second run sent a request to %s, want every step served from the cache", got.Path)
+	}
+	if groups := output.Report.Groups; len(groups) != 1 || groups[0].Method != GroupMethodCache {
+		t.Errorf("report groups = %+v, want one group from the cache", groups)
+	}
+	if data, err := os.ReadFile(input.OutputPath); err != nil || !strings.Contains(string(data), "鲍勃？\nBob?") {
+		t.Errorf("output = %q, %v", data, err)
+	}
+}
+
+func TestSubtitleAgentResume(t *testing.T) {
+	server, got := newStubServer(t, http.StatusOK, testAgentResponse)
+	agent, err := NewSubtitleAgent(context.Background(), &TranslatorConfig{Provider: ProviderOpenAI, APIKey: "sk-test", BaseURL: server.URL, Model: "m"})
+	if err != nil {
+		t.Fatalf("NewSubtitleAgent: %v", err)
+	}
+
+	// 两个分组中第一组和背景信息已经记录在断点日志中
+	input := newTestAgentInput(t, testAgentSRT+`
+3
+00:00:30,000 --> 00:00:31,000
+Goodbye, Bob.
+
+4
+00:00:31,500 --> 00:00:33,000
+Bob!
+`)
+	sub, err := parseInputSubtitle(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := GroupSubtitles(sub.Items, groupOptions(input, "m"))
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+	checkpoint, err := OpenCheckpoint(checkpointPath(input.OutputPath), input.SubtitlePath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.RecordSummary("restored summary"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoint.RecordGroup("zh", groups[0], []string{"恢复一", "恢复二"}); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Close()
+
+	input.Resume = true
+	output, err := agent.Run(context.Background(), input)
+	if err != nil || !output.Success {
+		t.Fatalf("Run = %+v, %v", output, err)
+	}
+
+	// 只请求翻译第二组，第一组的译文从断点日志恢复
+	var request struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(got.Body, &request); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(request.Messages); n == 0 || request.Messages[n-1].Content != `["Goodbye, Bob.","Bob!"]` {
+		t.Errorf("last request messages = %+v, want only the second group translated", request.Messages)
+	}
+	methods := make(map[int]string)
+	for _, group := range output.Report.Groups {
+		methods[group.Group] = group.Method
+	}
+	if methods[groups[0].ID] != GroupMethodCheckpoint || methods[groups[1].ID] == GroupMethodCheckpoint {
+		t.Errorf("group methods = %v, want only group %d restored from the checkpoint", methods, groups[0].ID)
+	}
+	data, err := os.ReadFile(input.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"恢复一\nHello, Bob.", "恢复二\nBob?", "你好，鲍勃。\nGoodbye, Bob.", "鲍勃？\nBob!"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("output does not contain %q:\n%s", want, data)
+		}
+	}
+	// 全部完成后删除断点日志
+	if _, err := os.Stat(checkpointPath(input.OutputPath)); !os.IsNotExist(err) {
+		t.Errorf("checkpoint still exists after a successful run: %v", err)
+	}
+}
+
+func TestSubtitleAgentReview(t *testing.T) {
+	server, _ := newStubServer(t, http.StatusOK, testAgentResponse)
+	agent, err := NewSubtitleAgent(context.Background(), &TranslatorConfig{Provider: ProviderOpenAI, APIKey: "sk-test", BaseURL: server.URL, Model: "m"})
+	if err != nil {
+		t.Fatalf("NewSubtitleAgent: %v", err)
+	}
+
+	input := newTestAgentInput(t, testAgentSRT)
+	input.Review = true
+	input.ReviewFix = true
+	if output, err := agent.Run(context.Background(), input); err != nil || !output.Success {
+		t.Fatalf("Run = %+v, %v", output, err)
+	}
+
+	// 审校报告记录原文、原来的译文和建议，--review-fix 时建议的译文写入输出文件
+	data, err := os.ReadFile(reviewReportPath(input.OutputPath, "zh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report ReviewReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	want := ReviewIssue{Index: 2, Type: "tone", Source: "Bob?", Translation: "鲍勃？", Problem: "too flat", Suggestion: "鲍勃！", Applied: true}
+	if report.Model != "m" || len(report.Issues) != 1 || report.Issues[0] != want {
+		t.Errorf("review report = %+v, want one applied issue %+v", report, want)
+	}
+	if data, err := os.ReadFile(input.OutputPath); err != nil || !strings.Contains(string(data), "鲍勃！\nBob?") {
+		t.Errorf("output = %q, %v, want the suggested translation", data, err)
+	}
+}
+
+func TestSubtitleAgentReportAndEvents(t *testing.T) {
+	server, _ := newStubServer(t, http.StatusOK, testAgentResponse)
+	agent, err := NewSubtitleAgent(context.Background(), &TranslatorConfig{Provider: ProviderOpenAI, APIKey: "sk-test", BaseURL: server.URL, Model: "m"})
+	if err != nil {
+		t.Fatalf("NewSubtitleAgent: %v", err)
+	}
+
+	var mu sync.Mutex
+	var events []Event
+	input := newTestAgentInput(t, testAgentSRT)
+	input.OnEvent = func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+	output, err := agent.Run(context.Background(), input)
+	if err != nil || !output.Success {
+		t.Fatalf("Run = %+v, %v", output, err)
+	}
+
+	// 运行报告写在输出文件旁，记录每个分组的字幕、译文来源和用量
+	data, err := os.ReadFile(runReportPath(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report RunReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Model != "m" || report.Cues != 2 || report.FallbackCues != 0 || !slices.Equal(report.Outputs, []string{input.OutputPath}) {
+		t.Errorf("report = %+v", &report)
+	}
+	if len(report.Groups) != 1 {
+		t.Fatalf("report groups = %+v, want 1", report.Groups)
+	}
+	group := report.Groups[0]
+	if !slices.Equal(group.Cues, []int{1, 2}) || group.Method != GroupMethodToolCall || group.Model != "m" || group.Attempts != 1 {
+		t.Errorf("group report = %+v", group)
+	}
+	// 背景总结和一个翻译分组，每个请求 15 个 token
+	if report.Usage == nil || report.Usage.Requests != 2 || report.Usage.PromptTokens != 20 || report.Usage.CompletionTokens != 10 {
+		t.Errorf("usage = %+v, want 2 requests with 20 prompt and 10 completion tokens", report.Usage)
+	}
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+		if e.Input != input.SubtitlePath {
+			t.Errorf("event %s input = %q, want %q", e.Type, e.Input, input.SubtitlePath)
+		}
+	}
+	if want := []string{EventParseDone, EventSummaryDone, EventGroupStarted, EventGroupDone, EventFinished}; !slices.Equal(types, want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	if done := events[3]; done.Method != GroupMethodToolCall || done.DoneCues != 2 || done.TotalCues != 2 || done.Model != "m" {
+		t.Errorf("group_done event = %+v", done)
+	}
+	if finished := events[4]; !finished.Success || !slices.Equal(finished.Outputs, []string{input.OutputPath}) || finished.Usage == nil {
+		t.Errorf("finished event = %+v", finished)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	defaultAnthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion        = "2023-06-01"
+	// anthropicMaxTokens 是每次回复的最大 token 数，Anthropic 接口要求必须指定
+	anthropicMaxTokens = 8192
+)
+
+// anthropicChatModel 使用 Anthropic Messages 接口（/v1/messages），也适用于兼容该接口的服务
+type anthropicChatModel struct {
+	config ProviderConfig
+	tools  []anthropicTool
+}
+
+type anthropicContent struct {
+	Type string `json:"type"`
+	// text
+	Text string `json:"text,omitempty"`
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func newAnthropicChatModel(ctx context.Context, config ProviderConfig) (model.ToolCallingChatModel, error) {
+	if len(config.ResponseSchema) > 0 {
+		return nil, errors.New("anthropic does not support JSON schema output, use --response-mode tool or text")
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = defaultAnthropicBaseURL
+	}
+	return &anthropicChatModel{config: config}, nil
+}
+
+func (m *anthropicChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound := &anthropicChatModel{config: m.config}
+	for _, tool := range tools {
+		params, err := toolParameters(tool)
+		if err != nil {
+			return nil, err
+		}
+		bound.tools = append(bound.tools, anthropicTool{Name: tool.Name, Description: tool.Desc, InputSchema: params})
+	}
+	return bound, nil
+}
+
+func (m *anthropicChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	req := anthropicRequest{Model: m.config.Model, MaxTokens: anthropicMaxTokens, Tools: m.tools}
+	var system []string
+	for _, msg := range input {
+		var role string
+		var content []anthropicContent
+		switch msg.Role {
+		case schema.System:
+			system = append(system, msg.Content)
+			continue
+		case schema.Assistant:
+			role = "assistant"
+			if msg.Content != "" {
+				content = append(content, anthropicContent{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				content = append(content, anthropicContent{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: toolArguments(call.Function.Arguments)})
+			}
+		case schema.Tool:
+			role = "user"
+			content = []anthropicContent{{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}}
+		default:
+			role = "user"
+			content = []anthropicContent{{Type: "text", Text: msg.Content}}
+		}
+
+		// 接口要求 user 和 assistant 交替出现，工具结果和随后的用户消息合并为一条
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == role {
+			req.Messages[n-1].Content = append(req.Messages[n-1].Content, content...)
+			continue
+		}
+		req.Messages = append(req.Messages, anthropicMessage{Role: role, Content: content})
+	}
+	req.System = strings.Join(system, "\n")
+
+	header := http.Header{}
+	header.Set("x-api-key", m.config.APIKey)
+	header.Set("anthropic-version", anthropicVersion)
+	var resp anthropicResponse
+	if err := postJSON(ctx, ProviderAnthropic, joinURL(m.config.BaseURL, "/v1/messages"), header, req, &resp); err != nil {
+		return nil, err
+	}
+
+	msg := schema.AssistantMessage("", nil)
+	var text []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "tool_use":
+			msg.ToolCalls = append(msg.ToolCalls, schema.ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: schema.FunctionCall{Name: block.Name, Arguments: string(block.Input)},
+			})
+		}
+	}
+	msg.Content = strings.Join(text, "")
+	msg.ResponseMeta = &schema.ResponseMeta{
+		FinishReason: resp.StopReason,
+		Usage: &schema.TokenUsage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}
+	return msg, nil
+}
+
+func (m *anthropicChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return singleMessageStream(m.Generate(ctx, input, opts...))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestAnthropicChatModelGenerate(t *testing.T) {
+	server, got := newStubServer(t, http.StatusOK, `{
+		"id": "msg_1",
+		"type": "message",
+		"role": "assistant",
+		"content": [
+			{"type": "text", "text": "Resubmitting."},
+			{"type": "tool_use", "id": "toolu_1", "name": "submit_translation", "input": {"translations": ["你好"]}}
+		],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 200, "output_tokens": 40}
+	}`)
+	chatModel := newBoundModel(t, ProviderAnthropic, ProviderConfig{APIKey: "sk-ant-test", BaseURL: server.URL, Model: "claude-sonnet-4-5"})
+
+	msg, err := chatModel.Generate(context.Background(), toolRoundTrip())
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if got.Path != "/v1/messages" {
+		t.Errorf("path = %s, want /v1/messages", got.Path)
+	}
+	if got.Header.Get("x-api-key") != "sk-ant-test" || got.Header.Get("anthropic-version") != anthropicVersion {
+		t.Errorf("headers = %v, want x-api-key and anthropic-version", got.Header)
+	}
+	var req anthropicRequest
+	if err := json.Unmarshal(got.Body, &req); err != nil {
+		t.Fatalf("request body: %v", err)
+	}
+	if req.Model != "claude-sonnet-4-5" || req.MaxTokens != anthropicMaxTokens || req.System != "sys" {
+		t.Errorf("model = %s, max_tokens = %d, system = %q", req.Model, req.MaxTokens, req.System)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Name != "submit_translation" || len(req.Tools[0].InputSchema) == 0 {
+		t.Errorf("tools = %+v, want submit_translation with input schema", req.Tools)
+	}
+	// 工具结果和随后的用户消息合并为一条 user 消息，保证 user 和 assistant 交替出现
+	if len(req.Messages) != 3 {
+		t.Fatalf("got %d messages, want 3: %+v", len(req.Messages), req.Messages)
+	}
+	for i, role := range []string{"user", "assistant", "user"} {
+		if req.Messages[i].Role != role {
+			t.Errorf("message %d role = %s, want %s", i, req.Messages[i].Role, role)
+		}
+	}
+	toolUse := req.Messages[1].Content
+	if len(toolUse) != 1 || toolUse[0].Type != "tool_use" || toolUse[0].ID != "call_0" || toolUse[0].Name != "submit_translation" ||
+		string(toolUse[0].Input) != `{"translations":["你好"]}` {
+		t.Errorf("assistant content = %+v, want tool_use call_0", toolUse)
+	}
+	result := req.Messages[2].Content
+	if len(result) != 2 || result[0].Type != "tool_result" || result[0].ToolUseID != "call_0" || result[0].Content != "length mismatch" ||
+		result[1].Type != "text" || result[1].Text != "again" {
+		t.Errorf("user content = %+v, want tool_result for call_0 followed by text", result)
+	}
+
+	checkSubmitCall(t, msg, "toolu_1")
+	checkUsage(t, msg, 200, 40)
+	if msg.Content != "Resubmitting." || msg.ResponseMeta.FinishReason != "tool_use" {
+		t.Errorf("content = %q, finish reason = %s", msg.Content, msg.ResponseMeta.FinishReason)
+	}
+}
+
+func TestAnthropicChatModelAPIError(t *testing.T) {
+	server, _ := newStubServer(t, http.StatusUnauthorized, `{"type": "error", "error": {"type": "authentication_error", "message": "invalid x-api-key"}}`)
+	chatModel := newBoundModel(t, ProviderAnthropic, ProviderConfig{APIKey: "bad", BaseURL: server.URL, Model: "claude-sonnet-4-5"})
+
+	_, err := chatModel.Generate(context.Background(), toolRoundTrip())
+	checkAPIError(t, err, ProviderAnthropic, http.StatusUnauthorized, "invalid x-api-key")
+}
+
+func TestAnthropicChatModelRejectsResponseSchema(t *testing.T) {
+	_, err := NewChatModel(context.Background(), ProviderAnthropic, ProviderConfig{Model: "claude-sonnet-4-5", ResponseSchema: json.RawMessage(`{"type":"object"}`)})
+	if err == nil {
+		t.Fatal("NewChatModel with a response schema succeeded, want error")
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ASSModeRebuild 丢弃原有样式，按 GenerateASS 重新生成整个脚本（默认）
+	ASSModeRebuild = "rebuild"
+	// ASSModeInline 保留原脚本，只在每条对白文本前加上译文
+	ASSModeInline = "inline"
+	// ASSModeCompanion 保留原脚本，为每条对白追加一条使用译文样式的对白
+	ASSModeCompanion = "companion"
+)
+
+var (
+	assOverrideBlockRegexp = regexp.MustCompile(`\{[^}]*\}`)
+	assLeadingTagsRegexp   = regexp.MustCompile(`^(\{[^}]*\})+`)
+	assDrawingRegexp       = regexp.MustCompile(`\\p[1-9]`)
+)
+
+// assTagNames 是覆盖标签的名称，按长度从长到短排列，用于从 \fnArial 这样的标签中取出名称
+var assTagNames = []string{
+	"xshad", "yshad", "xbord", "ybord", "alpha", "iclip",
+	"bord", "shad", "blur", "fscx", "fscy", "move", "clip", "fade",
+	"fsp", "fax", "fay", "frx", "fry", "frz", "org", "pos", "fad", "pbo",
+	"fs", "fn", "fr", "fe", "be", "an", "kf", "ko",
+	"1c", "2c", "3c", "4c", "1a", "2a", "3a", "4a",
+	"a", "b", "c", "i", "k", "K", "p", "q", "r", "s", "t", "u",
+}
+
+// assCompanionDropTags 是译文对白不沿用的标签：定位和剪裁标签会让译文与原文画在同一位置，
+// 卡拉 OK 标签的音节时间与译文无关
+var assCompanionDropTags = map[string]bool{
+	"pos": true, "move": true, "an": true, "a": true, "org": true, "clip": true, "iclip": true,
+	"k": true, "K": true, "kf": true, "ko": true,
+}
+
+var defaultASSEventFormat = []string{"Layer", "Start", "End", "Style", "Name", "MarginL", "MarginR", "MarginV", "Effect", "Text"}
+
+// ASSScript 按行保存原始 ASS 脚本，生成输出时只改写需要翻译的对白，其余内容原样输出
+type ASSScript struct {
+	lines   []string
+	newline string
+
+	styles        map[string]int
+	lastStyleLine int
+
+	eventFormat []string
+	events      []assEvent
+}
+
+type assEvent struct {
+	line   int
+	kind   string
+	fields []string
+}
+
+func (e assEvent) field(format []string, name string) string {
+	for i, f := range format {
+		if strings.EqualFold(f, name) && i < len(e.fields) {
+			return e.fields[i]
+		}
+	}
+	return ""
+}
+
+// splitLineTerminator 把一行拆成内容和行尾换行符
+func splitLineTerminator(line string) (string, string) {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2], "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return line[:len(line)-1], "\n"
+	}
+	return line, ""
+}
+
+func parseASSFormat(value string) []string {
+	parts := strings.Split(value, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func ParseASSScript(data string) (*ASSScript, error) {
+	script := &ASSScript{
+		lines:         strings.SplitAfter(data, "\n"),
+		newline:       "\n",
+		styles:        make(map[string]int),
+		lastStyleLine: -1,
+		eventFormat:   defaultASSEventFormat,
+	}
+	if strings.Contains(data, "\r\n") {
+		script.newline = "\r\n"
+	}
+
+	section := ""
+	for i, raw := range script.lines {
+		line, _ := splitLineTerminator(raw)
+		trimmed := strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = strings.ToLower(trimmed)
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimLeft(value, " ")
+
+		switch section {
+		case "[v4+ styles]", "[v4 styles]":
+			if key == "Style" {
+				name, _, _ := strings.Cut(value, ",")
+				script.styles[strings.TrimSpace(name)] = i
+				script.lastStyleLine = i
+			}
+		case "[events]":
+			switch key {
+			case "Format":
+				script.eventFormat = parseASSFormat(value)
+			case "Dialogue":
+				script.events = append(script.events, assEvent{
+					line:   i,
+					kind:   key,
+					fields: strings.SplitN(value, ",", len(script.eventFormat)),
+				})
+			}
+		}
+	}
+
+	if len(script.events) == 0 {
+		return nil, fmt.Errorf("no dialogue events found in ASS script")
+	}
+	return script, nil
+}
+
+// parseASSTime 解析 H:MM:SS.cc 格式的时间
+func parseASSTime(value string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid ASS time %q", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASS time %q", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASS time %q", value)
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASS time %q", value)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)), nil
+}
+
+// assPlainText 去掉覆盖标签，把 \N、\n 转换为换行、\h 转换为空格
+func assPlainText(text string) string {
+	text = assOverrideBlockRegexp.ReplaceAllString(text, "")
+	text = strings.NewReplacer("\\N", "\n", "\\n", "\n", "\\h", " ").Replace(text)
+	return strings.TrimSpace(text)
+}
+
+// ParseASSSubtitle 解析 ASS/SSA 脚本并保留原始内容，每条可翻译的对白对应一个 SubtitleItem，
+// 绘图（\p1 等）和空白对白不参与翻译
+func ParseASSSubtitle(filePath string) (*Subtitle, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subtitle file: %w", err)
+	}
+
+	script, err := ParseASSScript(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ASS script: %w", err)
+	}
+
+	sub := &Subtitle{assScript: script}
+	for i, event := range script.events {
+		text := event.field(script.eventFormat, "Text")
+		plain := assPlainText(text)
+		if plain == "" || assDrawingRegexp.MatchString(text) {
+			continue
+		}
+
+		startAt, err := parseASSTime(event.field(script.eventFormat, "Start"))
+		if err != nil {
+			return nil, err
+		}
+		endAt, err := parseASSTime(event.field(script.eventFormat, "End"))
+		if err != nil {
+			return nil, err
+		}
+
+		sub.Items = append(sub.Items, &SubtitleItem{
+			Index:    len(sub.Items) + 1,
+			StartAt:  startAt,
+			EndAt:    endAt,
+			Text:     plain,
+			assEvent: i,
+		})
+	}
+	return sub, nil
+}
+
+// assTags 把覆盖标签块拆成单个标签，例如 {\i1\pos(1,2)} 拆成 \i1 和 \pos(1,2)，括号中的反斜杠不拆分
+func assTags(blocks string) []string {
+	var tags []string
+	for _, block := range assOverrideBlockRegexp.FindAllString(blocks, -1) {
+		block = strings.TrimSuffix(strings.TrimPrefix(block, "{"), "}")
+		depth, start := 0, -1
+		for i, r := range block {
+			switch {
+			case r == '(':
+				depth++
+			case r == ')':
+				depth = max(depth-1, 0)
+			case r == '\\' && depth == 0:
+				if start >= 0 {
+					tags = append(tags, block[start:i])
+				}
+				start = i
+			}
+		}
+		if start >= 0 {
+			tags = append(tags, block[start:])
+		}
+	}
+	return tags
+}
+
+// assTagName 返回标签名称，例如 \fnArial 返回 fn，\1c&H0000FF& 返回 1c
+func assTagName(tag string) string {
+	tag = strings.TrimPrefix(tag, "\\")
+	for _, name := range assTagNames {
+		if strings.HasPrefix(tag, name) {
+			return name
+		}
+	}
+	return tag
+}
+
+// assCompanionTags 返回译文对白开头使用的覆盖标签。只沿用原文开头对整条对白生效的样式：
+// 去掉 \pos、\move、\an、\org 等定位标签，以免译文与原文重叠；原文后面又改写的标签也去掉，
+// 例如 {\i1}Hello{\i0} there 中的 \i1 只作用于前半句，不能让整条译文变成斜体
+func assCompanionTags(text string) string {
+	leading := assLeadingTagsRegexp.FindString(text)
+	overridden := make(map[string]bool)
+	for _, tag := range assTags(text[len(leading):]) {
+		overridden[assTagName(tag)] = true
+	}
+
+	var kept []string
+	for _, tag := range assTags(leading) {
+		name := assTagName(tag)
+		if !assCompanionDropTags[name] && !overridden[name] {
+			kept = append(kept, tag)
+		}
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(kept, "") + "}"
+}
+
+// companionStyleName 返回原样式对应某个目标语言的译文样式名
+func companionStyleName(style string, lang string) string {
+	return style + " " + LookupLanguage(lang).StyleName
+}
+
+// GenerateASSPreserved 在原始 ASS 脚本的基础上写入译文，除被翻译的对白外其余内容保持不变。
+// mode 为 ASSModeInline 时译文写在同一条对白中原文之前；为 ASSModeCompanion 时为每种语言追加一条
+// 使用复制自原样式的译文样式的对白
+func (s *Subtitle) GenerateASSPreserved(mode string, targetLangs ...string) (string, error) {
+	script := s.assScript
+	if script == nil {
+		return "", fmt.Errorf("subtitle was not parsed from an ASS script")
+	}
+
+	replace := make(map[int]string)
+	insertAfter := make(map[int][]string)
+	// companionStyles 按“原样式\x00语言”记录要创建的译文样式名，taken 是脚本中已有和将要创建的样式名（不区分大小写）
+	companionStyles := make(map[string]string)
+	taken := make(map[string]bool)
+	for name := range script.styles {
+		taken[strings.ToLower(name)] = true
+	}
+	// companionStyle 返回对白样式 style 对应目标语言的译文样式名。未定义的样式（渲染器按 Default 显示）
+	// 复制 Default，连 Default 也未定义时返回 false，对白保留原样式。译文样式名与已有样式重名时加上数字后缀
+	companionStyle := func(style string, lang string) (string, bool) {
+		style = strings.TrimPrefix(style, "*")
+		if _, ok := script.styles[style]; !ok {
+			style = "Default"
+			if _, ok := script.styles[style]; !ok {
+				return "", false
+			}
+		}
+		key := style + "\x00" + lang
+		if name, ok := companionStyles[key]; ok {
+			return name, true
+		}
+		base := companionStyleName(style, lang)
+		name := base
+		for n := 2; taken[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s %d", base, n)
+		}
+		taken[strings.ToLower(name)] = true
+		companionStyles[key] = name
+		return name, true
+	}
+
+	for _, item := range s.Items {
+		event := script.events[item.assEvent]
+		text := event.field(script.eventFormat, "Text")
+		leadingTags := assLeadingTagsRegexp.FindString(text)
+
+		var translations []string
+		for _, lang := range targetLangs {
+			if trans := item.Translation(lang); trans != "" {
+				translations = append(translations, escapeASSText(trans))
+			}
+		}
+		if len(translations) == 0 {
+			continue
+		}
+
+		switch mode {
+		case ASSModeInline:
+			fields := append([]string(nil), event.fields...)
+			fields[len(fields)-1] = leadingTags + strings.Join(translations, "\\N") + "\\N" + strings.TrimPrefix(text, leadingTags)
+			replace[event.line] = event.kind + ": " + strings.Join(fields, ",")
+		case ASSModeCompanion:
+			styleIndex := -1
+			for i, f := range script.eventFormat {
+				if strings.EqualFold(f, "Style") {
+					styleIndex = i
+				}
+			}
+			for _, lang := range targetLangs {
+				trans := item.Translation(lang)
+				if trans == "" {
+					continue
+				}
+				fields := append([]string(nil), event.fields...)
+				if styleIndex >= 0 && styleIndex < len(fields) {
+					if name, ok := companionStyle(fields[styleIndex], lang); ok {
+						fields[styleIndex] = name
+					}
+				}
+				fields[len(fields)-1] = assCompanionTags(text) + escapeASSText(trans)
+				insertAfter[event.line] = append(insertAfter[event.line], event.kind+": "+strings.Join(fields, ","))
+			}
+		default:
+			return "", fmt.Errorf("unknown ASS mode %q", mode)
+		}
+	}
+
+	// 为用到的原样式复制出译文样式，追加在最后一个 Style 之后
+	if mode == ASSModeCompanion && script.lastStyleLine >= 0 {
+		var styleLines []string
+		for _, key := range sortedKeys(companionStyles) {
+			style, _, _ := strings.Cut(key, "\x00")
+			content, _ := splitLineTerminator(script.lines[script.styles[style]])
+			_, value, _ := strings.Cut(content, ":")
+			fields := strings.Split(strings.TrimLeft(value, " "), ",")
+			fields[0] = companionStyles[key]
+			styleLines = append(styleLines, "Style: "+strings.Join(fields, ","))
+		}
+		insertAfter[script.lastStyleLine] = append(styleLines, insertAfter[script.lastStyleLine]...)
+	}
+
+	var builder strings.Builder
+	for i, raw := range script.lines {
+		content, terminator := splitLineTerminator(raw)
+		if replaced, ok := replace[i]; ok {
+			content = replaced
+		}
+		extra := insertAfter[i]
+		if len(extra) > 0 && terminator == "" {
+			builder.WriteString(content)
+			for _, line := range extra {
+				builder.WriteString(script.newline)
+				builder.WriteString(line)
+			}
+			continue
+		}
+		builder.WriteString(content)
+		builder.WriteString(terminator)
+		for _, line := range extra {
+			builder.WriteString(line)
+			builder.WriteString(terminator)
+		}
+	}
+	return builder.String(), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testASSScript = "\ufeff[Script Info]\r\n" +
+	"; Script generated by Aegisub\r\n" +
+	"Title: Test\r\n" +
+	"PlayResX: 1920\r\n" +
+	"PlayResY: 1080\r\n" +
+	"\r\n" +
+	"[V4+ Styles]\r\n" +
+	"Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\r\n" +
+	"Style: Default,Fansub Font,60,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,2,10,10,40,1\r\n" +
+	"Style: Sign,Arial,40,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,8,10,10,10,1\r\n" +
+	"\r\n" +
+	"[Events]\r\n" +
+	"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\r\n" +
+	"Comment: 0,0:00:00.00,0:00:01.00,Default,,0,0,0,,note, with comma\r\n" +
+	"Dialogue: 0,0:00:01.00,0:00:02.00,Default,Bob,0,0,0,,{\\i1}Hello, there.{\\i0}\r\n" +
+	"Dialogue: 1,0:00:02.50,0:00:04.00,Sign,,0,0,0,,{\\an8\\pos(100,200)\\fad(100,100)\\b1}EXIT\r\n" +
+	"Dialogue: 0,0:00:02.50,0:00:04.00,Default,,0,0,0,,{\\p1}m 0 0 l 10 10{\\p0}\r\n" +
+	"Dialogue: 0,0:00:05.00,0:00:06.00,Default,,0,0,0,,\r\n" +
+	"Dialogue: 0,0:00:07.00,0:00:08.00,Default,,0,0,0,,{\\i1}Goodbye.\r\n" +
+	"\r\n" +
+	"[Fonts]\r\n" +
+	"fontname: x.ttf\r\n" +
+	"M3\"`,[N\r\n"
+
+// parseTestASS 解析 testASSScript 并为每条对白写入中文和日文译文
+func parseTestASS(t *testing.T) *Subtitle {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.ass")
+	if err := os.WriteFile(path, []byte(testASSScript), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := ParseASSSubtitle(path)
+	if err != nil {
+		t.Fatalf("ParseASSSubtitle: %v", err)
+	}
+	if len(sub.Items) != 3 {
+		t.Fatalf("got %d items, want 3 (comment, drawing and blank lines are not translated)", len(sub.Items))
+	}
+	for _, item := range sub.Items {
+		item.SetTranslation("zh", "中:"+item.Text)
+		item.SetTranslation("ja", "日:"+item.Text)
+	}
+	return sub
+}
+
+func TestAssCompanionTags(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`Hello`, ``},
+		{`{\b1}Hello`, `{\b1}`},
+		{`{\pos(100,200)}EXIT`, ``},
+		{`{\an8\pos(100,200)\fad(100,100)\b1}EXIT`, `{\fad(100,100)\b1}`},
+		{`{\move(0,0,100,100)\org(5,5)\frz10}Sign`, `{\frz10}`},
+		{`{\clip(m 0 0 l 10 10)\fnArial\fs40}Sign`, `{\fnArial\fs40}`},
+		{`{\i1}Hello, there.{\i0}`, ``},
+		{`{\i1}Hello,{\i0} there.`, ``},
+		{`{\i1\b1}Hello, {\b0}there.`, `{\i1}`},
+		{`{\i1}Goodbye.`, `{\i1}`},
+		{`{\k20}Ka{\k30}ra`, ``},
+		{`{\fs40}{\c&H0000FF&}Red`, `{\fs40\c&H0000FF&}`},
+		{`{\t(0,500,\fscx120)\1c&HFF&}Grow{\fscx100}`, `{\t(0,500,\fscx120)\1c&HFF&}`},
+	}
+	for _, tt := range tests {
+		if got := assCompanionTags(tt.text); got != tt.want {
+			t.Errorf("assCompanionTags(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateASSPreservedCompanion(t *testing.T) {
+	sub := parseTestASS(t)
+	out, err := sub.GenerateASSPreserved(ASSModeCompanion, "zh", "ja")
+	if err != nil {
+		t.Fatalf("GenerateASSPreserved: %v", err)
+	}
+
+	// 去掉追加的译文样式和对白后，其余内容（包括 BOM、CRLF、注释、绘图和 [Fonts]）逐字节不变
+	var kept, added []string
+	for _, line := range strings.SplitAfter(out, "\n") {
+		if strings.Contains(line, " Chinese") || strings.Contains(line, " Japanese") {
+			added = append(added, strings.TrimSuffix(line, "\r\n"))
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if got := strings.Join(kept, ""); got != testASSScript {
+		t.Errorf("untouched lines changed:\n%q\nwant\n%q", got, testASSScript)
+	}
+
+	want := []string{
+		"Style: Default Japanese,Fansub Font,60,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,2,10,10,40,1",
+		"Style: Default Chinese,Fansub Font,60,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,2,10,10,40,1",
+		"Style: Sign Japanese,Arial,40,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,8,10,10,10,1",
+		"Style: Sign Chinese,Arial,40,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,8,10,10,10,1",
+		"Dialogue: 0,0:00:01.00,0:00:02.00,Default Chinese,Bob,0,0,0,,中:Hello, there.",
+		"Dialogue: 0,0:00:01.00,0:00:02.00,Default Japanese,Bob,0,0,0,,日:Hello, there.",
+		"Dialogue: 1,0:00:02.50,0:00:04.00,Sign Chinese,,0,0,0,,{\\fad(100,100)\\b1}中:EXIT",
+		"Dialogue: 1,0:00:02.50,0:00:04.00,Sign Japanese,,0,0,0,,{\\fad(100,100)\\b1}日:EXIT",
+		"Dialogue: 0,0:00:07.00,0:00:08.00,Default Chinese,,0,0,0,,{\\i1}中:Goodbye.",
+		"Dialogue: 0,0:00:07.00,0:00:08.00,Default Japanese,,0,0,0,,{\\i1}日:Goodbye.",
+	}
+	if strings.Join(added, "\n") != strings.Join(want, "\n") {
+		t.Errorf("added lines:\n%s\nwant\n%s", strings.Join(added, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGenerateASSPreservedInline(t *testing.T) {
+	sub := parseTestASS(t)
+	out, err := sub.GenerateASSPreserved(ASSModeInline, "zh")
+	if err != nil {
+		t.Fatalf("GenerateASSPreserved: %v", err)
+	}
+
+	// 只改写被翻译的对白，行数和其余各行保持不变
+	got := strings.SplitAfter(out, "\n")
+	orig := strings.SplitAfter(testASSScript, "\n")
+	if len(got) != len(orig) {
+		t.Fatalf("got %d lines, want %d", len(got), len(orig))
+	}
+	replaced := map[int]string{
+		14: "Dialogue: 0,0:00:01.00,0:00:02.00,Default,Bob,0,0,0,,{\\i1}中:Hello, there.\\NHello, there.{\\i0}\r\n",
+		15: "Dialogue: 1,0:00:02.50,0:00:04.00,Sign,,0,0,0,,{\\an8\\pos(100,200)\\fad(100,100)\\b1}中:EXIT\\NEXIT\r\n",
+		18: "Dialogue: 0,0:00:07.00,0:00:08.00,Default,,0,0,0,,{\\i1}中:Goodbye.\\NGoodbye.\r\n",
+	}
+	for i := range orig {
+		want, ok := replaced[i]
+		if !ok {
+			want = orig[i]
+		}
+		if got[i] != want {
+			t.Errorf("line %d = %q, want %q", i, got[i], want)
+		}
+	}
+}
+
+func TestGenerateASSPreservedCompanionStyles(t *testing.T) {
+	const header = "[V4+ Styles]\n" +
+		"Format: Name, Fontname, Fontsize\n"
+	const events = "\n[Events]\n" +
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n" +
+		"Dialogue: 0,0:00:01.00,0:00:02.00,*Default,,0,0,0,,One.\n" +
+		"Dialogue: 0,0:00:03.00,0:00:04.00,Missing,,0,0,0,,Two.\n"
+	tests := []struct {
+		name   string
+		styles string
+		want   []string
+	}{
+		{
+			// 未定义的样式按 Default 显示，译文样式复制 Default，并避开已有的同名样式
+			name:   "default",
+			styles: "Style: Default,Arial,60\nStyle: default chinese,Arial,40\n",
+			want: []string{
+				"Style: Default Chinese 2,Arial,60",
+				"Dialogue: 0,0:00:01.00,0:00:02.00,Default Chinese 2,,0,0,0,,中:One.",
+				"Dialogue: 0,0:00:03.00,0:00:04.00,Default Chinese 2,,0,0,0,,中:Two.",
+			},
+		},
+		{
+			// 连 Default 也未定义时保留对白原来的样式
+			name:   "no default",
+			styles: "Style: Sign,Arial,40\n",
+			want: []string{
+				"Dialogue: 0,0:00:01.00,0:00:02.00,*Default,,0,0,0,,中:One.",
+				"Dialogue: 0,0:00:03.00,0:00:04.00,Missing,,0,0,0,,中:Two.",
+			},
+		},
+	}
+	for _, tt := range tests {
+		script := header + tt.styles + events
+		path := filepath.Join(t.TempDir(), "test.ass")
+		if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sub, err := ParseASSSubtitle(path)
+		if err != nil {
+			t.Fatalf("%s: ParseASSSubtitle: %v", tt.name, err)
+		}
+		for _, item := range sub.Items {
+			item.SetTranslation("zh", "中:"+item.Text)
+		}
+		out, err := sub.GenerateASSPreserved(ASSModeCompanion, "zh")
+		if err != nil {
+			t.Fatalf("%s: GenerateASSPreserved: %v", tt.name, err)
+		}
+		var added []string
+		for _, line := range strings.Split(out, "\n") {
+			if !strings.Contains(script, line+"\n") {
+				added = append(added, line)
+			}
+		}
+		if strings.Join(added, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: added lines:\n%s\nwant\n%s", tt.name, strings.Join(added, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	batchStatusOK      = "OK"
+	batchStatusSkipped = "SKIPPED"
+	batchStatusFailed  = "FAILED"
+)
+
+type BatchOptions struct {
+	Root             string
+	Include          []string
+	NameTemplate     string
+	OutputFormat     string
+	SourceLang       string
+	TargetLangs      []string
+	CombineOutput    bool
+	ASSStyle         ASSStyle
+	ASSMode          string
+	Glossaries       []string
+	Resume           bool
+	ExtractTerms     bool
+	Review           bool
+	ReviewFix        bool
+	MaxFallbackRatio float64
+	Grouping         GroupOptions
+	// Force 为 true 时即使输出文件已存在也重新翻译
+	Force bool
+	// OnEvent 接收每个文件翻译过程中的事件，可以为 nil
+	OnEvent EventFunc
+}
+
+type BatchResult struct {
+	InputPath   string
+	OutputPaths []string
+	Status      string
+	Err         error
+	Duration    time.Duration
+}
+
+// batchOutputFormat 确定输出格式：未指定时沿用输入文件的格式，不支持的格式回退为 srt
+func batchOutputFormat(format string, inputPath string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(inputPath), ".")); ext {
+	case "srt", "ass", "vtt":
+		return ext
+	default:
+		return "srt"
+	}
+}
+
+// renderNameTemplate 渲染输出文件名模板，支持 {name}、{ext}，{lang} 保留给 Agent 按目标语言替换
+func renderNameTemplate(template string, inputPath string, format string) string {
+	base := filepath.Base(inputPath)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	replacer := strings.NewReplacer("{name}", name, "{ext}", format)
+	return filepath.Join(filepath.Dir(inputPath), replacer.Replace(template))
+}
+
+func batchAgentInput(opts BatchOptions, inputPath string) AgentInput {
+	format := batchOutputFormat(opts.OutputFormat, inputPath)
+	// 只有 ASS 输入才能保留原始脚本，其他输入总是重新生成
+	assMode := opts.ASSMode
+	if !isASSFile(inputPath) {
+		assMode = ASSModeRebuild
+	}
+	return AgentInput{
+		SubtitlePath:     inputPath,
+		OutputPath:       renderNameTemplate(opts.NameTemplate, inputPath, format),
+		OutputFormat:     format,
+		SourceLang:       opts.SourceLang,
+		TargetLangs:      opts.TargetLangs,
+		CombineOutput:    opts.CombineOutput,
+		ASSStyle:         opts.ASSStyle,
+		ASSMode:          assMode,
+		Glossaries:       opts.Glossaries,
+		Resume:           opts.Resume,
+		ExtractTerms:     opts.ExtractTerms,
+		Review:           opts.Review,
+		ReviewFix:        opts.ReviewFix,
+		MaxFallbackRatio: opts.MaxFallbackRatio,
+		Grouping:         opts.Grouping,
+		OnEvent:          opts.OnEvent,
+	}
+}
+
+// FindSubtitleFiles 遍历目录，返回匹配 include 模式（不区分大小写）的字幕文件，
+// 其中本身是其他字幕翻译结果的文件会被排除
+func FindSubtitleFiles(opts BatchOptions) ([]string, error) {
+	var candidates []string
+	err := filepath.WalkDir(opts.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		for _, pattern := range opts.Include {
+			matched, err := filepath.Match(strings.ToLower(pattern), strings.ToLower(d.Name()))
+			if err != nil {
+				return fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+			}
+			if matched {
+				candidates = append(candidates, path)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make(map[string]bool)
+	for _, path := range candidates {
+		for _, file := range outputTargets(batchAgentInput(opts, path)) {
+			outputs[file.Path] = true
+		}
+	}
+
+	var files []string
+	for _, path := range candidates {
+		if !outputs[path] {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+func RunBatch(ctx context.Context, agent *SubtitleAgent, opts BatchOptions) ([]BatchResult, error) {
+	files, err := FindSubtitleFiles(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
+	logBatch.Info("找到字幕文件", "found subtitle files", "dir", opts.Root, "files", len(files))
+
+	results := make([]BatchResult, 0, len(files))
+	for i, path := range files {
+		input := batchAgentInput(opts, path)
+		result := BatchResult{InputPath: path}
+
+		targets := outputTargets(input)
+		for _, file := range targets {
+			result.OutputPaths = append(result.OutputPaths, file.Path)
+		}
+
+		if !opts.Force && allExist(result.OutputPaths) {
+			logBatch.Info("已存在翻译结果，跳过", "translation already exists, skipping", "file", path, "n", i+1, "total", len(files))
+			result.Status = batchStatusSkipped
+			results = append(results, result)
+			continue
+		}
+
+		logBatch.Info("开始翻译", "translating", "file", path, "n", i+1, "total", len(files))
+		start := time.Now()
+		output, err := agent.Run(ctx, input)
+		result.Duration = time.Since(start)
+		switch {
+		case err != nil:
+			result.Status = batchStatusFailed
+			result.Err = err
+		case !output.Success:
+			result.Status = batchStatusFailed
+			result.Err = fmt.Errorf("%s", output.Message)
+		default:
+			result.Status = batchStatusOK
+			result.OutputPaths = output.OutputPaths
+		}
+		if result.Err != nil {
+			logBatch.Error("翻译失败", "translation failed", "file", path, "n", i+1, "total", len(files), "err", result.Err)
+		}
+		results = append(results, result)
+
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+	}
+
+	return results, nil
+}
+
+// EstimateBatch 估算批量翻译的用量而不调用模型，与 RunBatch 一样跳过已有译文的文件
+func EstimateBatch(opts BatchOptions, tracker *UsageTracker, config *TranslatorConfig) ([]*DryRunEstimate, error) {
+	files, err := FindSubtitleFiles(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	var estimates []*DryRunEstimate
+	for _, path := range files {
+		input := batchAgentInput(opts, path)
+		if !opts.Force {
+			var outputs []string
+			for _, file := range outputTargets(input) {
+				outputs = append(outputs, file.Path)
+			}
+			if allExist(outputs) {
+				continue
+			}
+		}
+
+		estimate, err := EstimateUsage(tracker, input, config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		estimates = append(estimates, estimate)
+	}
+	return estimates, nil
+}
+
+func allExist(paths []string) bool {
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return len(paths) > 0
+}
+
+// PrintBatchSummary 以表格形式输出每个文件的处理结果
+func PrintBatchSummary(w io.Writer, results []BatchResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tFILE\tTIME\tDETAIL")
+
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.Status]++
+		detail := strings.Join(result.OutputPaths, ", ")
+		if result.Err != nil {
+			detail = result.Err.Error()
+		}
+		duration := "-"
+		if result.Duration > 0 {
+			duration = result.Duration.Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Status, result.InputPath, duration, detail)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d files: %d succeeded, %d skipped, %d failed\n",
+		len(results), counts[batchStatusOK], counts[batchStatusSkipped], counts[batchStatusFailed])
+}
+
+func newBatchCmd() *cobra.Command {
+	opts := BatchOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "batch <dir>",
+		Short: "Translate every subtitle file under a directory",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Root = args[0]
+			opts.SourceLang = sourceLang
+			opts.TargetLangs = targetLangs
+			opts.CombineOutput = combine
+			opts.ASSStyle = assStyle
+			opts.ASSMode = assMode
+			opts.Glossaries = glossaries
+			opts.Resume = resume
+			opts.ExtractTerms = extractTerms
+			opts.Review = review || reviewFix
+			opts.ReviewFix = reviewFix
+			opts.MaxFallbackRatio = maxFallbackRatio
+			opts.Grouping = grouping
+
+			if dryRun {
+				tracker := NewUsageTracker()
+				estimates, err := EstimateBatch(opts, tracker, dryRunConfig())
+				if err != nil {
+					return err
+				}
+				PrintDryRunEstimate(os.Stdout, estimates, tracker.Summary(modelPrices))
+				return nil
+			}
+
+			if err := requireAPIKey(); err != nil {
+				return err
+			}
+			onEvent, err := newEventHandler()
+			if err != nil {
+				return err
+			}
+			opts.OnEvent = onEvent
+			ctx := context.Background()
+
+			agent, err := NewSubtitleAgent(ctx, newTranslatorConfig())
+			if err != nil {
+				return fmt.Errorf("failed to create agent: %w", err)
+			}
+
+			results, err := RunBatch(ctx, agent, opts)
+			// 输出 JSON 事件流时 stdout 只保留事件
+			summaryOut := os.Stdout
+			if eventsFormat != "" {
+				summaryOut = os.Stderr
+			}
+			PrintBatchSummary(summaryOut, results)
+			if err != nil {
+				return err
+			}
+			for _, result := range results {
+				if result.Status == batchStatusFailed {
+					return fmt.Errorf("some files failed to translate")
+				}
+			}
+			return nil
+		},
+	}
+
+	addTranslateFlags(cmd)
+	addProgressFlags(cmd)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the estimated token usage and cost of translating the files without calling the model")
+	cmd.Flags().StringSliceVar(&opts.Include, "include", []string{"*.srt", "*.ass", "*.vtt"}, "Glob patterns of subtitle file names to translate (case-insensitive)")
+	cmd.Flags().StringVar(&opts.NameTemplate, "name-template", "{name}.{lang}.{ext}", "Output file name template; supports {name}, {lang} and {ext}")
+	cmd.Flags().StringVarP(&opts.OutputFormat, "format", "f", "", "Output format (srt, ass or vtt, default: same as input)")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Translate files even if their outputs already exist")
+	return cmd
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindSubtitleFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"movie.SRT",
+		"movie.zh.srt",
+		"show.ass",
+		"show.zh.ass",
+		"notes.txt",
+		filepath.Join("season 1", "ep1.Vtt"),
+		filepath.Join("season 1", "ep1.zh.vtt"),
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 模式和文件名都不区分大小写，本工具生成的译文文件不作为输入
+	files, err := FindSubtitleFiles(BatchOptions{
+		Root:         root,
+		Include:      []string{"*.SRT", "*.ass", "*.VTT"},
+		NameTemplate: "{name}.{lang}.{ext}",
+		TargetLangs:  []string{"zh"},
+	})
+	if err != nil {
+		t.Fatalf("FindSubtitleFiles: %v", err)
+	}
+	want := []string{
+		filepath.Join(root, "movie.SRT"),
+		filepath.Join(root, "season 1", "ep1.Vtt"),
+		filepath.Join(root, "show.ass"),
+	}
+	if !slices.Equal(files, want) {
+		t.Errorf("files = %q, want %q", files, want)
+	}
+
+	if _, err := FindSubtitleFiles(BatchOptions{Root: root, Include: []string{"[*.srt"}}); err == nil {
+		t.Error("FindSubtitleFiles with an invalid pattern succeeded, want error")
+	}
+}
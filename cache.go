@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TranslationCache 以文件形式缓存分组翻译结果，每个条目一个 JSON 文件
+type TranslationCache struct {
+	dir string
+}
+
+type translationCacheEntry struct {
+	Model        string    `json:"model"`
+	SourceLang   string    `json:"source_lang"`
+	TargetLang   string    `json:"target_lang"`
+	Texts        []string  `json:"texts"`
+	Translations []string  `json:"translations"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type CacheStats struct {
+	Dir     string
+	Entries int
+	Bytes   int64
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+// DefaultCacheDir 返回用户缓存目录下的 subai 翻译缓存目录
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache dir: %w", err)
+	}
+	return filepath.Join(dir, "subai", "translations"), nil
+}
+
+func NewTranslationCache(dir string) (*TranslationCache, error) {
+	if dir == "" {
+		var err error
+		dir, err = DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	return &TranslationCache{dir: dir}, nil
+}
+
+// translationCacheKey 由模型、提示词版本、背景信息、语言和分组原文计算缓存键
+func translationCacheKey(modelName string, promptVersion string, summary string, sourceLang string, targetLang string, texts []string) string {
+	data, _ := json.Marshal([]any{modelName, promptVersion, summary, sourceLang, targetLang, texts})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *TranslationCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Get 读取缓存，命中时刷新文件修改时间以便 prune 按最近使用时间清理
+func (c *TranslationCache) Get(key string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry translationCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return entry.Translations, true
+}
+
+// Put 原子地写入缓存条目
+func (c *TranslationCache) Put(key string, entry translationCacheEntry) error {
+	if c == nil {
+		return nil
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	entry.CreatedAt = time.Now()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+func (c *TranslationCache) walk(fn func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+}
+
+func (c *TranslationCache) Stats() (CacheStats, error) {
+	stats := CacheStats{Dir: c.dir}
+	err := c.walk(func(path string, info fs.FileInfo) error {
+		stats.Entries++
+		stats.Bytes += info.Size()
+		if stats.Oldest.IsZero() || info.ModTime().Before(stats.Oldest) {
+			stats.Oldest = info.ModTime()
+		}
+		if info.ModTime().After(stats.Newest) {
+			stats.Newest = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to scan cache: %w", err)
+	}
+	return stats, nil
+}
+
+// Prune 删除超过 olderThan 未被使用的条目，olderThan 为 0 时清空全部缓存
+func (c *TranslationCache) Prune(olderThan time.Duration) (int, int64, error) {
+	removed := 0
+	var freed int64
+	cutoff := time.Now().Add(-olderThan)
+	err := c.walk(func(path string, info fs.FileInfo) error {
+		if olderThan > 0 && info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	if err != nil {
+		return removed, freed, fmt.Errorf("failed to prune cache: %w", err)
+	}
+	return removed, freed, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTranslationCacheStatsAndPrune(t *testing.T) {
+	cache, err := NewTranslationCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent := translationCacheKey("m", promptVersion, "", "en", "zh", []string{"recent"})
+	stale := translationCacheKey("m", promptVersion, "", "en", "zh", []string{"stale"})
+	used := translationCacheKey("m", promptVersion, "", "en", "zh", []string{"used"})
+	for _, key := range []string{recent, stale, used} {
+		if err := cache.Put(key, translationCacheEntry{Model: "m", Texts: []string{key}, Translations: []string{"T"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for _, key := range []string{stale, used} {
+		if err := os.Chtimes(cache.path(key), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.Entries != 3 || stats.Bytes <= 0 || !stats.Oldest.Equal(old) || stats.Newest.Before(old) {
+		t.Errorf("stats = %+v, want 3 entries, oldest %s", stats, old)
+	}
+
+	// 命中缓存会刷新修改时间，prune 按最近使用时间而不是写入时间清理
+	if _, ok := cache.Get(used); !ok {
+		t.Fatal("Get(used) missed")
+	}
+	removed, freed, err := cache.Prune(24 * time.Hour)
+	if err != nil || removed != 1 || freed <= 0 {
+		t.Fatalf("Prune(24h) = %d, %d, %v, want 1 entry removed", removed, freed, err)
+	}
+	if _, ok := cache.Get(stale); ok {
+		t.Error("stale entry still cached after prune")
+	}
+	for _, key := range []string{recent, used} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("entry %s removed by prune", key)
+		}
+	}
+
+	// olderThan 为 0 时清空全部缓存
+	if removed, _, err := cache.Prune(0); err != nil || removed != 2 {
+		t.Errorf("Prune(0) = %d, %v, want 2 entries removed", removed, err)
+	}
+	if stats, err := cache.Stats(); err != nil || stats.Entries != 0 {
+		t.Errorf("stats after Prune(0) = %+v, %v, want no entries", stats, err)
+	}
+}
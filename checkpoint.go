@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+const (
+	checkpointRecordHeader  = "header"
+	checkpointRecordSummary = "summary"
+	checkpointRecordGroup   = "group"
+)
+
+// Checkpoint 是追加写入的 JSONL 断点日志，记录每个已完成分组的翻译结果
+type Checkpoint struct {
+	path string
+
+	mu      sync.Mutex
+	file    *os.File
+	summary string
+	groups  map[string]checkpointRecord
+}
+
+type checkpointRecord struct {
+	Type         string   `json:"type"`
+	InputHash    string   `json:"input_hash,omitempty"`
+	Summary      string   `json:"summary,omitempty"`
+	TargetLang   string   `json:"target_lang,omitempty"`
+	Group        int      `json:"group,omitempty"`
+	Indices      []int    `json:"indices,omitempty"`
+	Translations []string `json:"translations,omitempty"`
+}
+
+// checkpointPath 返回输出文件对应的断点日志路径
+func checkpointPath(outputPath string) string {
+	return outputPath + ".subai-journal"
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// OpenCheckpoint 打开断点日志。resume 为 true 时加载已有记录，
+// 但输入文件哈希不一致的日志会被丢弃；resume 为 false 时总是重新开始
+func OpenCheckpoint(path string, inputPath string, resume bool) (*Checkpoint, error) {
+	inputHash, err := hashFile(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash input file: %w", err)
+	}
+
+	c := &Checkpoint{
+		path:   path,
+		groups: make(map[string]checkpointRecord),
+	}
+
+	var records []checkpointRecord
+	if resume {
+		records, err = readCheckpoint(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+		if len(records) > 0 && records[0].InputHash != inputHash {
+			logCheckpoint.Warn("输入文件已变化，丢弃旧的断点日志", "input file changed, discarding old checkpoint", "path", path)
+			records = nil
+		}
+	}
+	if len(records) == 0 {
+		records = []checkpointRecord{{Type: checkpointRecordHeader, InputHash: inputHash}}
+	}
+
+	for _, record := range records {
+		c.apply(record)
+	}
+
+	// 重写日志以去掉崩溃时可能残留的不完整行，然后以追加方式继续写入
+	if err := writeCheckpoint(path, records); err != nil {
+		return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	c.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+
+	if resume {
+		logCheckpoint.Info("已加载已完成分组", "loaded completed groups", "groups", len(c.groups))
+	}
+	return c, nil
+}
+
+func readCheckpoint(path string) ([]checkpointRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []checkpointRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record checkpointRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// 最后一行可能因为进程中断而不完整，之后的内容全部忽略
+			break
+		}
+		if len(records) == 0 && record.Type != checkpointRecordHeader {
+			return nil, nil
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+func writeCheckpoint(path string, records []checkpointRecord) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func checkpointGroupKey(targetLang string, group int) string {
+	return fmt.Sprintf("%s/%d", targetLang, group)
+}
+
+func (c *Checkpoint) apply(record checkpointRecord) {
+	switch record.Type {
+	case checkpointRecordSummary:
+		c.summary = record.Summary
+	case checkpointRecordGroup:
+		c.groups[checkpointGroupKey(record.TargetLang, record.Group)] = record
+	}
+}
+
+func (c *Checkpoint) append(record checkpointRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := c.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := c.file.Sync(); err != nil {
+		return err
+	}
+	c.apply(record)
+	return nil
+}
+
+// Summary 返回日志中保存的背景信息
+func (c *Checkpoint) Summary() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.summary, c.summary != ""
+}
+
+func (c *Checkpoint) RecordSummary(summary string) error {
+	return c.append(checkpointRecord{Type: checkpointRecordSummary, Summary: summary})
+}
+
+// Lookup 返回分组在目标语言下已完成的翻译，分组包含的字幕发生变化时视为未完成
+func (c *Checkpoint) Lookup(targetLang string, group SubtitleGroup) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	record, ok := c.groups[checkpointGroupKey(targetLang, group.ID)]
+	if !ok || !slices.Equal(record.Indices, group.Indices) || len(record.Translations) != len(group.Indices) {
+		return nil, false
+	}
+	return record.Translations, true
+}
+
+func (c *Checkpoint) RecordGroup(targetLang string, group SubtitleGroup, translations []string) error {
+	return c.append(checkpointRecord{
+		Type:         checkpointRecordGroup,
+		TargetLang:   targetLang,
+		Group:        group.ID,
+		Indices:      group.Indices,
+		Translations: translations,
+	})
+}
+
+func (c *Checkpoint) Close() error {
+	return c.file.Close()
+}
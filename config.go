@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// Config 对应 config.yaml。顶层的键是所有命令的默认值，profiles 下是可通过 --profile 选择的命名配置，
+// 键名与命令行参数相同（也可以用下划线代替连字符），例如 base_url、model、target_lang。
+// prices 按模型名配置每百万 token 的价格，用于计算费用
+type Config struct {
+	DefaultProfile string                  `yaml:"default_profile"`
+	Profiles       map[string]ConfigValues `yaml:"profiles"`
+	Prices         map[string]ModelPrice   `yaml:"prices"`
+	Values         ConfigValues            `yaml:",inline"`
+
+	// path 是读取的配置文件路径，用于错误信息，没有读取文件时为空
+	path string
+}
+
+type ConfigValues map[string]any
+
+// envAliases 返回除 SUBAI_* 之外也会读取的环境变量，优先级低于对应的 SUBAI_* 变量，
+// 例如 --provider openai 时读取 OPENAI_API_KEY 和 OPENAI_BASE_URL
+func envAliases(providerName string) map[string]string {
+	aliases := make(map[string]string)
+	p, err := lookupProvider(providerName)
+	if err != nil {
+		return aliases
+	}
+	if p.KeyEnv != "" {
+		aliases["api-key"] = p.KeyEnv
+	}
+	if p.BaseURLEnv != "" {
+		aliases["base-url"] = p.BaseURLEnv
+	}
+	return aliases
+}
+
+// defaultConfigPaths 返回按顺序查找的默认配置文件路径：先是 $XDG_CONFIG_HOME/subai/config.yaml
+// （未设置时为 ~/.config/subai/config.yaml，各平台相同），其次是系统的用户配置目录，
+// 例如 macOS 上的 ~/Library/Application Support/subai/config.yaml
+func defaultConfigPaths() []string {
+	var paths []string
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		paths = append(paths, filepath.Join(dir, "subai", "config.yaml"))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".config", "subai", "config.yaml"))
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		if path := filepath.Join(dir, "subai", "config.yaml"); !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// DefaultConfigPath 返回默认配置文件路径：defaultConfigPaths 中第一个存在的文件，都不存在时返回第一个路径
+func DefaultConfigPath() (string, error) {
+	paths := defaultConfigPaths()
+	if len(paths) == 0 {
+		return "", errors.New("failed to locate user config dir: neither $XDG_CONFIG_HOME nor $HOME is set")
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return paths[0], nil
+}
+
+// LoadConfig 读取配置文件，path 为空时读取默认路径，默认配置文件不存在时返回空配置
+func LoadConfig(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		var err error
+		path, err = DefaultConfigPath()
+		if err != nil {
+			return &Config{}, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// 拒绝 prices 等结构中的未知字段，以免拼错的键被静默忽略；顶层和 profiles 中的键是命令行参数名，按参数查找
+	var cfg Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	cfg.path = path
+	return &cfg, nil
+}
+
+// lookup 按参数名查找配置值并转换成可以传给 pflag 的字符串
+func (v ConfigValues) lookup(name string) (string, bool) {
+	value, ok := v[name]
+	if !ok {
+		value, ok = v[strings.ReplaceAll(name, "-", "_")]
+	}
+	if !ok || value == nil {
+		return "", false
+	}
+
+	if list, ok := value.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), true
+	}
+	return fmt.Sprint(value), true
+}
+
+func lookupEnv(name string, aliases map[string]string) (string, bool) {
+	key := "SUBAI_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value, true
+	}
+	if alias, ok := aliases[name]; ok {
+		if value, ok := os.LookupEnv(alias); ok && value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// applyConfig 把环境变量和配置文件中的值填入未在命令行显式指定的参数，
+// 优先级为：命令行参数 > 环境变量 > 所选 profile > 配置文件顶层默认值
+func applyConfig(cmd *cobra.Command) error {
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	modelPrices = cfg.Prices
+
+	profileName := profile
+	if profileName == "" {
+		profileName, _ = lookupEnv("profile", nil)
+	}
+	if profileName == "" {
+		profileName = cfg.DefaultProfile
+	}
+
+	var profileValues ConfigValues
+	if profileName != "" {
+		var ok bool
+		profileValues, ok = cfg.Profiles[profileName]
+		if !ok {
+			if cfg.path == "" {
+				return fmt.Errorf("profile %q not found: no config file", profileName)
+			}
+			return fmt.Errorf("profile %q not found in config file %s", profileName, cfg.path)
+		}
+	}
+
+	var aliases map[string]string
+	apply := func(f *pflag.Flag) error {
+		if f.Changed || f.Name == "config" || f.Name == "profile" {
+			return nil
+		}
+
+		value, ok := lookupEnv(f.Name, aliases)
+		if !ok {
+			value, ok = profileValues.lookup(f.Name)
+		}
+		if !ok {
+			value, ok = cfg.Values.lookup(f.Name)
+		}
+		if !ok {
+			return nil
+		}
+
+		if err := f.Value.Set(value); err != nil {
+			if cfg.path == "" {
+				return fmt.Errorf("invalid value %q for %s from environment: %w", value, f.Name, err)
+			}
+			return fmt.Errorf("invalid value %q for %s from environment or config file %s: %w", value, f.Name, cfg.path, err)
+		}
+		return nil
+	}
+
+	// 先确定模型服务，再按模型服务读取对应的 API Key 和地址环境变量
+	if f := cmd.Flags().Lookup("provider"); f != nil {
+		if err := apply(f); err != nil {
+			return err
+		}
+		aliases = envAliases(providerName)
+	}
+
+	var setErr error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if setErr == nil {
+			setErr = apply(f)
+		}
+	})
+	return setErr
+}
+
+// requireAPIKey 检查模型服务是否存在，以及需要 API Key 时是否通过命令行、环境变量或配置文件提供了 API Key
+func requireAPIKey() error {
+	p, err := lookupProvider(providerName)
+	if err != nil {
+		return err
+	}
+	if apiKey == "" && p.RequiresKey {
+		env := "SUBAI_API_KEY"
+		if p.KeyEnv != "" {
+			env = p.KeyEnv + " / " + env
+		}
+		return fmt.Errorf("API key is required for provider %s: use --api-key, %s, or api_key in the config file", providerName, env)
+	}
+	return nil
+}
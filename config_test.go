@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultConfigPath(t *testing.T) {
+	home := t.TempDir()
+	xdg := t.TempDir()
+	t.Setenv("HOME", home)
+
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	if got, _ := DefaultConfigPath(); got != filepath.Join(xdg, "subai", "config.yaml") {
+		t.Errorf("with XDG_CONFIG_HOME: DefaultConfigPath = %s", got)
+	}
+
+	// 未设置或设置为相对路径时忽略 XDG_CONFIG_HOME，各平台都使用 ~/.config
+	for _, value := range []string{"", "relative/dir"} {
+		t.Setenv("XDG_CONFIG_HOME", value)
+		if got, _ := DefaultConfigPath(); got != filepath.Join(home, ".config", "subai", "config.yaml") {
+			t.Errorf("XDG_CONFIG_HOME=%q: DefaultConfigPath = %s, want ~/.config/subai/config.yaml", value, got)
+		}
+	}
+
+	path := filepath.Join(home, ".config", "subai", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("model: gpt-4o\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if value, _ := cfg.Values.lookup("model"); value != "gpt-4o" {
+		t.Errorf("model from ~/.config/subai/config.yaml = %q, want gpt-4o", value)
+	}
+}
+
+func TestLoadConfigUnknownFields(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// 拼错的价格字段不会被静默忽略，错误信息中带有配置文件路径
+	path := write("typo.yaml", "prices:\n  my-model:\n    input: 1\n    ouput: 2\n")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "ouput") {
+		t.Errorf("LoadConfig(typo) error = %v, want an unknown field error naming %s", err, path)
+	}
+
+	path = write("ok.yaml", "model: gpt-4o\nprices:\n  my-model:\n    input: 1\n    output: 2\n")
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig(ok): %v", err)
+	}
+	if cfg.Prices["my-model"] != (ModelPrice{Input: 1, Output: 2}) {
+		t.Errorf("prices = %+v", cfg.Prices)
+	}
+
+	// 空文件是合法的空配置
+	if _, err := LoadConfig(write("empty.yaml", "")); err != nil {
+		t.Errorf("LoadConfig(empty): %v", err)
+	}
+}
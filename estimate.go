@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 估算时对模型输出长度的假设
+const (
+	// estimatedSummaryTokens 是背景信息总结（2-3 句话）的 token 数
+	estimatedSummaryTokens = 150
+	// estimatedTermTokens 是提取的每条术语的 token 数
+	estimatedTermTokens = 25
+	// estimatedReviewTokens 是每个审校窗口输出的 token 数
+	estimatedReviewTokens = 300
+	// anthropicToolSystemTokens 是 Anthropic 在请求带工具时自动加入的工具调用系统提示的 token 数
+	anthropicToolSystemTokens = 346
+	// toolWrapperTokens 是 OpenAI 兼容接口、Ollama 等把工具定义渲染进提示词时额外的格式和说明的 token 数
+	toolWrapperTokens = 20
+	// translationTokenRatio 是译文相对原文的 token 数之比，按译成中日韩文字时的膨胀估算
+	translationTokenRatio = 1.2
+)
+
+// DryRunEstimate 是不调用模型时对一个字幕文件的估算
+type DryRunEstimate struct {
+	Input  string
+	Cues   int
+	Groups int
+}
+
+// responseOverheadTokens 估算每个翻译请求中响应模式带来的额外输入 token 数：tool 模式下是 submit_translation
+// 的工具定义以及模型服务为工具调用加入的提示，json-schema 模式下是随请求发送的 JSON Schema（Ollama 只用它约束解码，
+// 不计入输入），text 模式没有额外开销。模型服务不支持该响应模式时返回与实际运行时相同的错误
+func responseOverheadTokens(providerName string, mode string) (int, error) {
+	if err := validResponseMode(mode); err != nil {
+		return 0, err
+	}
+	if _, err := lookupProvider(providerName); err != nil {
+		return 0, err
+	}
+	info := (&SubmitTranslationTool{}).Info()
+	params, err := toolParameters(info)
+	if err != nil {
+		return 0, err
+	}
+
+	switch mode {
+	case ResponseModeText:
+		return 0, nil
+	case ResponseModeJSONSchema:
+		switch providerName {
+		case ProviderAnthropic:
+			return 0, errors.New("anthropic does not support JSON schema output, use --response-mode tool or text")
+		case ProviderOllama:
+			return 0, nil
+		}
+		return estimateTokens(string(params)), nil
+	}
+
+	definition := estimateTokens(info.Name + info.Desc + string(params))
+	switch providerName {
+	case ProviderAnthropic:
+		return definition + anthropicToolSystemTokens, nil
+	case ProviderGemini:
+		return definition, nil
+	}
+	return definition + toolWrapperTokens, nil
+}
+
+// EstimateUsage 按实际运行时的方式解析和分组字幕，模拟每个请求估算各阶段的 token 用量，不调用模型。
+// config 提供模型服务、模型、响应模式和并发数，与实际运行时的设置相同。
+// 估算假设没有命中翻译缓存、没有重试，结果累加到 tracker 中，便于批量估算时汇总
+func EstimateUsage(tracker *UsageTracker, input AgentInput, config *TranslatorConfig) (*DryRunEstimate, error) {
+	model, reviewModel := config.Model, config.ReviewModel
+	overhead, err := responseOverheadTokens(config.Provider, config.ResponseMode)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := parseInputSubtitle(input)
+	if err != nil {
+		return nil, err
+	}
+	groups := GroupSubtitles(sub.Items, groupOptions(input, model))
+	estimate := &DryRunEstimate{Input: input.SubtitlePath, Cues: len(sub.Items), Groups: len(groups)}
+	if len(sub.Items) == 0 {
+		return estimate, nil
+	}
+	if reviewModel == "" {
+		reviewModel = model
+	}
+
+	// 只用于匹配术语表，不会请求模型
+	t := &Translator{glossaries: make(map[string]*Glossary)}
+	for _, spec := range input.Glossaries {
+		lang, path := parseGlossarySpec(spec)
+		glossary, err := LoadGlossary(path)
+		if err != nil {
+			return nil, err
+		}
+		t.AddGlossary(lang, glossary)
+	}
+
+	source := LookupLanguage(input.SourceLang)
+	sample := summarySample(sub.Items)
+	addEstimate(tracker, model, StageSummary, messageTokens(summaryMessages(filepath.Base(input.SubtitlePath), sample)), estimatedSummaryTokens)
+	// 背景信息的内容未知，用同样长度的占位文本估算
+	context := strings.Repeat("x", estimatedSummaryTokens*4)
+
+	for _, targetLang := range input.TargetLangs {
+		target := LookupLanguage(targetLang)
+
+		if input.ExtractTerms {
+			path := termsGlossaryPath(input.OutputPath, targetLang)
+			if glossary, err := LoadGlossary(path); err == nil {
+				t.AddGlossary(targetLang, glossary)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			} else {
+				prompt := estimateTokens(fmt.Sprintf(termExtractionPrompt, source.Name, target.Name, maxTermsPerChunk))
+				for _, chunk := range termChunks(sub.Items) {
+					addEstimate(tracker, model, StageTerms, prompt+estimateTokens(chunk), maxTermsPerChunk*estimatedTermTokens)
+				}
+			}
+		}
+
+		for i, group := range groups {
+			// 逐组翻译时上一组的译文作为前文，用原文代替估算；并发翻译时只提供前文的原文
+			if i > 0 && config.Concurrency <= 1 {
+				group.SetPrevTranslations(groups[i-1].Texts)
+			}
+			jsonArray, _ := json.Marshal(group.Texts)
+			glossaryText := formatGlossaryPrompt(t.matchGlossary(target, group.Texts))
+			systemPrompt := translateSystemPrompt(group, source, target, context, glossaryText, config.ResponseMode)
+			prompt := estimateTokens(systemPrompt) + estimateTokens(string(jsonArray)) + overhead
+			addEstimate(tracker, model, StageTranslate, prompt, int(float64(estimateTokens(string(jsonArray)))*translationTokenRatio))
+		}
+
+		if input.Review {
+			prompt := estimateTokens(fmt.Sprintf(reviewPrompt, source.Name, target.Name) + fmt.Sprintf(reviewContextPrompt, context))
+			for start := 0; start < len(sub.Items); start += reviewWindowSize {
+				cues := make([]reviewCue, 0, reviewWindowSize)
+				for i, item := range sub.Items[start:min(start+reviewWindowSize, len(sub.Items))] {
+					cues = append(cues, reviewCue{Index: start + i + 1, Source: item.Text, Translation: item.Text})
+				}
+				jsonArray, _ := json.Marshal(cues)
+				addEstimate(tracker, reviewModel, StageReview, prompt+estimateTokens(string(jsonArray)), estimatedReviewTokens)
+			}
+		}
+	}
+	return estimate, nil
+}
+
+func addEstimate(tracker *UsageTracker, model string, stage string, promptTokens int, completionTokens int) {
+	tracker.Add(model, stage, &schema.TokenUsage{PromptTokens: promptTokens, CompletionTokens: completionTokens})
+}
+
+// PrintDryRunEstimate 输出每个文件的字幕数和分组数，以及按阶段汇总的估算用量和费用
+func PrintDryRunEstimate(w io.Writer, estimates []*DryRunEstimate, summary *UsageSummary) {
+	cues, groups := 0, 0
+	for _, estimate := range estimates {
+		fmt.Fprintf(w, "%s: %d cues, %d groups\n", estimate.Input, estimate.Cues, estimate.Groups)
+		cues += estimate.Cues
+		groups += estimate.Groups
+	}
+	if len(estimates) > 1 {
+		fmt.Fprintf(w, "total: %d files, %d cues, %d groups\n", len(estimates), cues, groups)
+	}
+	fmt.Fprintln(w)
+	PrintUsageSummary(w, summary)
+	fmt.Fprintln(w, "\nestimate only: no API calls were made; assumes no cache hits and no retries")
+}
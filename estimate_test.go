@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testSRT = `1
+00:00:01,000 --> 00:00:02,000
+Hello, there.
+
+2
+00:00:02,500 --> 00:00:04,000
+How are you?
+
+3
+00:00:10,000 --> 00:00:12,000
+See you tomorrow.
+`
+
+// estimatePrompt 返回按 config 估算 testSRT 时翻译阶段的输入 token 数
+func estimatePrompt(t *testing.T, config *TranslatorConfig) int {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.srt")
+	if err := os.WriteFile(path, []byte(testSRT), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tracker := NewUsageTracker()
+	input := AgentInput{SubtitlePath: path, OutputPath: filepath.Join(t.TempDir(), "out.srt"), SourceLang: "en", TargetLangs: []string{"zh"}}
+	if _, err := EstimateUsage(tracker, input, config); err != nil {
+		t.Fatalf("EstimateUsage(%+v): %v", config, err)
+	}
+	for _, stage := range tracker.Summary(nil).Stages {
+		if stage.Stage == StageTranslate {
+			return stage.PromptTokens
+		}
+	}
+	t.Fatalf("no translate stage in estimate")
+	return 0
+}
+
+func TestEstimateUsageResponseMode(t *testing.T) {
+	prompt := func(provider string, mode string) int {
+		return estimatePrompt(t, &TranslatorConfig{Provider: provider, Model: "m", ResponseMode: mode})
+	}
+
+	openaiTool := prompt(ProviderOpenAI, ResponseModeTool)
+	if got := prompt(ProviderOpenAI, ""); got != openaiTool {
+		t.Errorf("default response mode = %d tokens, want the same as tool mode (%d)", got, openaiTool)
+	}
+	// 三种响应模式的提示词不同，text 模式不发送工具定义或 JSON Schema
+	openaiText := prompt(ProviderOpenAI, ResponseModeText)
+	if openaiText >= openaiTool {
+		t.Errorf("openai text = %d tokens, want fewer than tool mode (%d)", openaiText, openaiTool)
+	}
+	if got := prompt(ProviderOpenAI, ResponseModeJSONSchema); got <= openaiText {
+		t.Errorf("openai json-schema = %d tokens, want more than text mode (%d)", got, openaiText)
+	}
+	// Ollama 的 format 只约束解码，不计入输入
+	if overhead, err := responseOverheadTokens(ProviderOllama, ResponseModeJSONSchema); err != nil || overhead != 0 {
+		t.Errorf("ollama json-schema overhead = %d, %v, want 0", overhead, err)
+	}
+	// Anthropic 为工具调用额外加入系统提示，每个请求（这里有两个分组）都要计入
+	if got, want := prompt(ProviderAnthropic, ResponseModeTool), openaiTool-2*toolWrapperTokens+2*anthropicToolSystemTokens; got != want {
+		t.Errorf("anthropic tool = %d tokens, want %d", got, want)
+	}
+
+	for _, config := range []*TranslatorConfig{
+		{Provider: ProviderAnthropic, Model: "m", ResponseMode: ResponseModeJSONSchema},
+		{Provider: ProviderOpenAI, Model: "m", ResponseMode: "xml"},
+		{Provider: "azure", Model: "m"},
+	} {
+		if _, err := EstimateUsage(NewUsageTracker(), AgentInput{}, config); err == nil {
+			t.Errorf("EstimateUsage(%+v) succeeded, want error", config)
+		}
+	}
+}
+
+func TestEstimateUsageConcurrency(t *testing.T) {
+	// 并发翻译时不提供上一组的译文，估算的提示词更短
+	sequential := estimatePrompt(t, &TranslatorConfig{Model: "m", Concurrency: 1})
+	concurrent := estimatePrompt(t, &TranslatorConfig{Model: "m", Concurrency: 4})
+	if concurrent >= sequential {
+		t.Errorf("concurrent estimate = %d tokens, want fewer than sequential (%d)", concurrent, sequential)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 运行过程中发出的事件类型
+const (
+	EventParseDone    = "parse_done"
+	EventSummaryDone  = "summary_done"
+	EventGroupStarted = "group_started"
+	EventGroupDone    = "group_done"
+	EventRetry        = "retry"
+	// EventModelFallback 表示分组换用下一个备用模型重新翻译
+	EventModelFallback = "model_fallback"
+	// EventAPIRetry 表示模型请求遇到暂时性错误，等待后重试
+	EventAPIRetry = "api_retry"
+	EventFinished = "finished"
+)
+
+// Event 是运行过程中的一个事件，按类型只填写相关字段
+type Event struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Input      string    `json:"input,omitempty"`
+	TargetLang string    `json:"target_lang,omitempty"`
+	// Group 为分组序号，只在分组相关的事件中出现
+	Group *int `json:"group,omitempty"`
+
+	// parse_done: Cues 为字幕条数；group_started/group_done: Cues 为分组的字幕条数
+	Cues    int    `json:"cues,omitempty"`
+	Summary string `json:"summary,omitempty"`
+
+	// group_done: Model 为最终采用的译文所用的模型；model_fallback: Model 为换用的模型
+	Model string `json:"model,omitempty"`
+
+	// group_done
+	Method       string `json:"method,omitempty"`
+	FallbackCues int    `json:"fallback_cues,omitempty"`
+	Error        string `json:"error,omitempty"`
+	DoneGroups   int    `json:"done_groups,omitempty"`
+	TotalGroups  int    `json:"total_groups,omitempty"`
+	DoneCues     int    `json:"done_cues,omitempty"`
+	TotalCues    int    `json:"total_cues,omitempty"`
+	Tokens       int    `json:"tokens,omitempty"`
+
+	// retry、model_fallback、api_retry
+	Attempt int    `json:"attempt,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+
+	// api_retry: Stage 为请求所属的阶段，DelaySeconds 为重试前等待的秒数
+	Stage        string  `json:"stage,omitempty"`
+	DelaySeconds float64 `json:"delay_seconds,omitempty"`
+
+	// finished
+	Success bool          `json:"success,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Outputs []string      `json:"outputs,omitempty"`
+	Usage   *UsageSummary `json:"usage,omitempty"`
+}
+
+// EventFunc 接收事件，可能被多个 goroutine 并发调用
+type EventFunc func(Event)
+
+// NewJSONEventWriter 返回把事件逐行写成 JSON（NDJSON）的 EventFunc
+func NewJSONEventWriter(w io.Writer) EventFunc {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(e)
+	}
+}
+
+// isTerminal 判断文件是否为交互式终端
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ProgressDisplay 在终端最后一行显示翻译进度。它同时作为日志的输出，
+// 写入日志前先清除进度行，写入后重新绘制，避免日志和进度行混在一起
+type ProgressDisplay struct {
+	mu  sync.Mutex
+	out io.Writer
+
+	line        string
+	targetLang  string
+	doneGroups  int
+	totalGroups int
+	doneCues    int
+	totalCues   int
+	tokens      int
+	// translated 和 started 只统计实际请求模型的分组，用于估算剩余时间
+	translated int
+	started    time.Time
+}
+
+func NewProgressDisplay(out io.Writer) *ProgressDisplay {
+	return &ProgressDisplay{out: out}
+}
+
+func (p *ProgressDisplay) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	n, err := p.out.Write(b)
+	p.draw()
+	return n, err
+}
+
+func (p *ProgressDisplay) clear() {
+	if p.line != "" {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+}
+
+func (p *ProgressDisplay) draw() {
+	if p.line != "" {
+		fmt.Fprint(p.out, p.line)
+	}
+}
+
+// HandleEvent 根据事件更新进度行
+func (p *ProgressDisplay) HandleEvent(e Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch e.Type {
+	case EventGroupStarted:
+		if p.started.IsZero() {
+			p.started = e.Time
+		}
+		p.targetLang = e.TargetLang
+	case EventGroupDone:
+		p.targetLang = e.TargetLang
+		p.doneGroups, p.totalGroups = e.DoneGroups, e.TotalGroups
+		p.doneCues, p.totalCues = e.DoneCues, e.TotalCues
+		p.tokens = e.Tokens
+		if e.Method != GroupMethodCheckpoint {
+			p.translated++
+		}
+	case EventFinished:
+		// 批量翻译时下一个文件重新开始计数
+		p.clear()
+		p.line, p.targetLang = "", ""
+		p.doneGroups, p.totalGroups, p.doneCues, p.totalCues, p.tokens = 0, 0, 0, 0, 0
+		p.translated, p.started = 0, time.Time{}
+		return
+	default:
+		return
+	}
+
+	p.clear()
+	p.line = p.render()
+	p.draw()
+}
+
+func (p *ProgressDisplay) render() string {
+	var b strings.Builder
+	if p.targetLang != "" {
+		fmt.Fprintf(&b, "[%s] ", p.targetLang)
+	}
+	fmt.Fprintf(&b, "groups %d/%d | cues %d/%d", p.doneGroups, p.totalGroups, p.doneCues, p.totalCues)
+	if p.translated > 0 && p.doneGroups < p.totalGroups {
+		elapsed := time.Since(p.started)
+		eta := elapsed / time.Duration(p.translated) * time.Duration(p.totalGroups-p.doneGroups)
+		fmt.Fprintf(&b, " | ETA %s", eta.Round(time.Second))
+	}
+	if p.tokens > 0 {
+		fmt.Fprintf(&b, " | tokens %d", p.tokens)
+	}
+	return b.String()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// FailedGroups 对应输出文件旁的失败分组记录（例如 movie.srt -> movie.failed.json），
+// 保存重新翻译这些分组所需的运行参数。已完成的分组保存在断点日志中，
+// retry-failed 以 --resume 的方式重新运行，只翻译失败的分组，再重新写入输出文件
+type FailedGroups struct {
+	Input        string         `json:"input"`
+	Output       string         `json:"output"`
+	Format       string         `json:"format"`
+	SourceLang   string         `json:"source_lang"`
+	TargetLangs  []string       `json:"target_langs"`
+	Combine      bool           `json:"combine,omitempty"`
+	ASSMode      string         `json:"ass_mode,omitempty"`
+	ASSStyle     ASSStyle       `json:"ass_style"`
+	Glossaries   []string       `json:"glossaries,omitempty"`
+	ExtractTerms bool           `json:"extract_terms,omitempty"`
+	Review       bool           `json:"review,omitempty"`
+	ReviewFix    bool           `json:"review_fix,omitempty"`
+	ReviewModel  string         `json:"review_model,omitempty"`
+	Grouping     GroupOptions   `json:"grouping"`
+	Groups       []*GroupReport `json:"groups"`
+}
+
+// failedGroupsPath 返回失败分组记录的保存路径，与运行报告放在一起
+func failedGroupsPath(input AgentInput) string {
+	return runSidecarPath(input, ".failed.json")
+}
+
+// newFailedGroups 记录重新翻译所需的运行参数，文件路径保存为绝对路径，从其他目录运行 retry-failed 时也能找到
+func newFailedGroups(input AgentInput, reviewModel string, groups []*GroupReport) *FailedGroups {
+	var glossaries []string
+	for _, spec := range input.Glossaries {
+		lang, path := parseGlossarySpec(spec)
+		if lang != "" {
+			glossaries = append(glossaries, lang+"="+absPath(path))
+		} else {
+			glossaries = append(glossaries, absPath(path))
+		}
+	}
+	return &FailedGroups{
+		Input:        absPath(input.SubtitlePath),
+		Output:       absPath(input.OutputPath),
+		Format:       input.OutputFormat,
+		SourceLang:   input.SourceLang,
+		TargetLangs:  input.TargetLangs,
+		Combine:      input.CombineOutput,
+		ASSMode:      input.ASSMode,
+		ASSStyle:     input.ASSStyle,
+		Glossaries:   glossaries,
+		ExtractTerms: input.ExtractTerms,
+		Review:       input.Review,
+		ReviewFix:    input.ReviewFix,
+		ReviewModel:  reviewModel,
+		Grouping:     input.Grouping,
+		Groups:       groups,
+	}
+}
+
+// absPath 返回 path 的绝对路径，无法确定当前目录时原样返回
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+// AgentInput 返回重新翻译失败分组的运行参数：与原来的运行相同，并从断点日志恢复已完成的分组。
+// 断点日志保存的是审校前的译文，原来的运行审校过时重新审校全部译文，--review-fix 的修改才不会丢失
+func (f *FailedGroups) AgentInput() AgentInput {
+	return AgentInput{
+		SubtitlePath:     f.Input,
+		OutputPath:       f.Output,
+		OutputFormat:     f.Format,
+		SourceLang:       f.SourceLang,
+		TargetLangs:      f.TargetLangs,
+		CombineOutput:    f.Combine,
+		ASSStyle:         f.ASSStyle,
+		ASSMode:          f.ASSMode,
+		Glossaries:       f.Glossaries,
+		ExtractTerms:     f.ExtractTerms,
+		Review:           f.Review,
+		ReviewFix:        f.ReviewFix,
+		Grouping:         f.Grouping,
+		Resume:           true,
+		MaxFallbackRatio: 1,
+	}
+}
+
+func SaveFailedGroups(path string, failed *FailedGroups) error {
+	data, err := json.MarshalIndent(failed, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal failed groups: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write failed groups: %w", err)
+	}
+	return nil
+}
+
+func LoadFailedGroups(path string) (*FailedGroups, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read failed groups: %w", err)
+	}
+	var failed FailedGroups
+	if err := json.Unmarshal(data, &failed); err != nil {
+		return nil, fmt.Errorf("failed to parse failed groups %s: %w", path, err)
+	}
+	return &failed, nil
+}
+
+// findFailedGroups 查找输出文件对应的失败分组记录。path 可以是 -o 指定的输出路径、
+// 按语言拆分后的某个输出文件（例如 movie.zh.srt），也可以直接是 .failed.json 文件
+func findFailedGroups(path string) (string, error) {
+	if strings.HasSuffix(path, ".failed.json") {
+		return path, nil
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	candidates := []string{base + ".failed.json"}
+	if ext := filepath.Ext(base); ext != "" {
+		candidates = append(candidates, strings.TrimSuffix(base, ext)+".failed.json")
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no failed groups recorded for %s (looked for %s)", path, strings.Join(candidates, ", "))
+}
+
+func newRetryFailedCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "retry-failed <output>",
+		Short: "Re-translate the groups that failed in a previous run and patch them into its output",
+		Long: "Re-translate only the groups recorded in the .failed.json file next to a previous run's output, then rewrite the output with them.\n" +
+			"The input, languages, format and grouping are taken from the previous run; model settings such as --model, --provider and --fallback-model can be changed.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := findFailedGroups(args[0])
+			if err != nil {
+				return err
+			}
+			failed, err := LoadFailedGroups(path)
+			if err != nil {
+				return err
+			}
+			input := failed.AgentInput()
+			journal := checkpointPath(input.OutputPath)
+			if _, err := os.Stat(journal); errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("checkpoint journal %s not found, the completed groups cannot be restored; rerun the whole translation instead", journal)
+			}
+
+			if err := requireAPIKey(); err != nil {
+				return err
+			}
+			onEvent, err := newEventHandler()
+			if err != nil {
+				return err
+			}
+			input.OnEvent = onEvent
+
+			logMain.Info("重新翻译失败的分组", "retrying failed groups", "groups", len(failed.Groups), "input", failed.Input, "output", failed.Output, "model", modelName)
+			ctx := context.Background()
+			config := newTranslatorConfig()
+			config.ReviewModel = failed.ReviewModel
+			agent, err := NewSubtitleAgent(ctx, config)
+			if err != nil {
+				return fmt.Errorf("failed to create agent: %w", err)
+			}
+
+			output, err := agent.Run(ctx, input)
+			if err != nil {
+				return err
+			}
+			// 输出 JSON 事件流时 stdout 只保留事件
+			if eventsFormat == "" && output.Report != nil && output.Report.Usage != nil {
+				PrintUsageSummary(os.Stdout, output.Report.Usage)
+			}
+			if !output.Success {
+				return errors.New(output.Message)
+			}
+			if eventsFormat == "" {
+				fmt.Println(output.Message)
+			}
+			return nil
+		},
+	}
+
+	addModelFlags(cmd)
+	addProgressFlags(cmd)
+	return cmd
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFailedGroupsRoundTrip(t *testing.T) {
+	input := AgentInput{
+		SubtitlePath:     "movie.srt",
+		OutputPath:       "movie.zh.srt",
+		OutputFormat:     "srt",
+		SourceLang:       "en",
+		TargetLangs:      []string{"zh"},
+		Glossaries:       []string{"zh=names.csv"},
+		ExtractTerms:     true,
+		Review:           true,
+		ReviewFix:        true,
+		Grouping:         GroupOptions{MaxGapSeconds: 2, MaxCues: 20, MaxTokens: 800},
+		MaxFallbackRatio: 0.1,
+	}
+	groups := []*GroupReport{{Group: 3, TargetLang: "zh", Cues: []int{7, 8}, Error: "boom"}}
+
+	path := filepath.Join(t.TempDir(), "movie.failed.json")
+	if err := SaveFailedGroups(path, newFailedGroups(input, "gpt-4o", groups)); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := LoadFailedGroups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.ReviewModel != "gpt-4o" || !reflect.DeepEqual(failed.Groups, groups) {
+		t.Errorf("review model = %q, groups = %+v", failed.ReviewModel, failed.Groups)
+	}
+
+	// 重新翻译时沿用原来的运行参数，包括审校，并从断点日志恢复已完成的分组；相对路径保存为绝对路径，
+	// 从其他目录运行 retry-failed 时也指向原来的文件
+	want := input
+	want.SubtitlePath, _ = filepath.Abs("movie.srt")
+	want.OutputPath, _ = filepath.Abs("movie.zh.srt")
+	glossary, _ := filepath.Abs("names.csv")
+	want.Glossaries = []string{"zh=" + glossary}
+	want.Resume = true
+	want.MaxFallbackRatio = 1
+	if got := failed.AgentInput(); !reflect.DeepEqual(got, want) {
+		t.Errorf("AgentInput() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const defaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// geminiChatModel 使用 Gemini 的 generateContent 接口，也适用于兼容该接口的服务
+type geminiChatModel struct {
+	config ProviderConfig
+	tools  []geminiFunctionDeclaration
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent `json:"contents"`
+	Tools             []geminiTool    `json:"tools,omitempty"`
+	GenerationConfig  *geminiConfig   `json:"generationConfig,omitempty"`
+}
+
+type geminiConfig struct {
+	ResponseMimeType string          `json:"responseMimeType,omitempty"`
+	ResponseSchema   json.RawMessage `json:"responseSchema,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func newGeminiChatModel(ctx context.Context, config ProviderConfig) (model.ToolCallingChatModel, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = defaultGeminiBaseURL
+	}
+	return &geminiChatModel{config: config}, nil
+}
+
+func (m *geminiChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound := &geminiChatModel{config: m.config}
+	for _, tool := range tools {
+		params, err := toolParameters(tool)
+		if err != nil {
+			return nil, err
+		}
+		bound.tools = append(bound.tools, geminiFunctionDeclaration{Name: tool.Name, Description: tool.Desc, Parameters: params})
+	}
+	return bound, nil
+}
+
+func (m *geminiChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	names := toolCallNames(input)
+	var req geminiRequest
+	if len(m.tools) > 0 {
+		req.Tools = []geminiTool{{FunctionDeclarations: m.tools}}
+	}
+	var system []geminiPart
+	for _, msg := range input {
+		var role string
+		var parts []geminiPart
+		switch msg.Role {
+		case schema.System:
+			system = append(system, geminiPart{Text: msg.Content})
+			continue
+		case schema.Assistant:
+			role = "model"
+			if msg.Content != "" {
+				parts = append(parts, geminiPart{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{Name: call.Function.Name, Args: toolArguments(call.Function.Arguments)}})
+			}
+		case schema.Tool:
+			// 工具结果必须是 JSON 对象，不是对象时包装为 {"result": ...}
+			response := json.RawMessage(msg.Content)
+			if !json.Valid(response) || !strings.HasPrefix(strings.TrimSpace(msg.Content), "{") {
+				response, _ = json.Marshal(map[string]string{"result": msg.Content})
+			}
+			role = "user"
+			parts = []geminiPart{{FunctionResponse: &geminiFunctionResponse{Name: names[msg.ToolCallID], Response: response}}}
+		default:
+			role = "user"
+			parts = []geminiPart{{Text: msg.Content}}
+		}
+
+		// 相邻的同角色消息合并为一条
+		if n := len(req.Contents); n > 0 && req.Contents[n-1].Role == role {
+			req.Contents[n-1].Parts = append(req.Contents[n-1].Parts, parts...)
+			continue
+		}
+		req.Contents = append(req.Contents, geminiContent{Role: role, Parts: parts})
+	}
+	if len(system) > 0 {
+		req.SystemInstruction = &geminiContent{Parts: system}
+	}
+	if len(m.config.ResponseSchema) > 0 {
+		req.GenerationConfig = &geminiConfig{ResponseMimeType: "application/json", ResponseSchema: m.config.ResponseSchema}
+	}
+
+	header := http.Header{}
+	header.Set("x-goog-api-key", m.config.APIKey)
+	endpoint := joinURL(m.config.BaseURL, "/models/"+url.PathEscape(m.config.Model)+":generateContent")
+	var resp geminiResponse
+	if err := postJSON(ctx, ProviderGemini, endpoint, header, req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Candidates) == 0 {
+		return nil, fmt.Errorf("gemini response has no candidates")
+	}
+
+	candidate := resp.Candidates[0]
+	msg := schema.AssistantMessage("", nil)
+	var text []string
+	for i, part := range candidate.Content.Parts {
+		if part.FunctionCall != nil {
+			// Gemini 的函数调用没有 ID，按顺序生成一个
+			msg.ToolCalls = append(msg.ToolCalls, schema.ToolCall{
+				ID:       fmt.Sprintf("call_%d", i),
+				Type:     "function",
+				Function: schema.FunctionCall{Name: part.FunctionCall.Name, Arguments: string(part.FunctionCall.Args)},
+			})
+			continue
+		}
+		text = append(text, part.Text)
+	}
+	msg.Content = strings.Join(text, "")
+	msg.ResponseMeta = &schema.ResponseMeta{
+		FinishReason: candidate.FinishReason,
+		Usage: &schema.TokenUsage{
+			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      resp.UsageMetadata.PromptTokenCount + resp.UsageMetadata.CandidatesTokenCount,
+		},
+	}
+	return msg, nil
+}
+
+func (m *geminiChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return singleMessageStream(m.Generate(ctx, input, opts...))
+}
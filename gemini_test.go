@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestGeminiChatModelGenerate(t *testing.T) {
+	server, got := newStubServer(t, http.StatusOK, `{
+		"candidates": [{
+			"content": {"role": "model", "parts": [
+				{"text": "Resubmitting."},
+				{"functionCall": {"name": "submit_translation", "args": {"translations": ["你好"]}}}
+			]},
+			"finishReason": "STOP"
+		}],
+		"usageMetadata": {"promptTokenCount": 300, "candidatesTokenCount": 25, "totalTokenCount": 325}
+	}`)
+	chatModel := newBoundModel(t, ProviderGemini, ProviderConfig{APIKey: "gk-test", BaseURL: server.URL, Model: "gemini-2.5-flash"})
+
+	msg, err := chatModel.Generate(context.Background(), toolRoundTrip())
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if got.Path != "/models/gemini-2.5-flash:generateContent" {
+		t.Errorf("path = %s, want /models/gemini-2.5-flash:generateContent", got.Path)
+	}
+	if got.Header.Get("x-goog-api-key") != "gk-test" {
+		t.Errorf("x-goog-api-key = %q, want gk-test", got.Header.Get("x-goog-api-key"))
+	}
+	var req geminiRequest
+	if err := json.Unmarshal(got.Body, &req); err != nil {
+		t.Fatalf("request body: %v", err)
+	}
+	if req.SystemInstruction == nil || len(req.SystemInstruction.Parts) != 1 || req.SystemInstruction.Parts[0].Text != "sys" {
+		t.Errorf("systemInstruction = %+v, want sys", req.SystemInstruction)
+	}
+	if len(req.Tools) != 1 || len(req.Tools[0].FunctionDeclarations) != 1 || req.Tools[0].FunctionDeclarations[0].Name != "submit_translation" {
+		t.Errorf("tools = %+v, want submit_translation", req.Tools)
+	}
+	if req.GenerationConfig != nil {
+		t.Errorf("generationConfig = %+v, want none without a response schema", req.GenerationConfig)
+	}
+	if len(req.Contents) != 3 {
+		t.Fatalf("got %d contents, want 3: %+v", len(req.Contents), req.Contents)
+	}
+	for i, role := range []string{"user", "model", "user"} {
+		if req.Contents[i].Role != role {
+			t.Errorf("content %d role = %s, want %s", i, req.Contents[i].Role, role)
+		}
+	}
+	call := req.Contents[1].Parts
+	if len(call) != 1 || call[0].FunctionCall == nil || call[0].FunctionCall.Name != "submit_translation" ||
+		string(call[0].FunctionCall.Args) != `{"translations":["你好"]}` {
+		t.Errorf("model parts = %+v, want submit_translation functionCall", call)
+	}
+	// 工具结果按函数名关联，不是 JSON 对象的结果包装为 {"result": ...}
+	parts := req.Contents[2].Parts
+	if len(parts) != 2 || parts[0].FunctionResponse == nil || parts[0].FunctionResponse.Name != "submit_translation" ||
+		string(parts[0].FunctionResponse.Response) != `{"result":"length mismatch"}` || parts[1].Text != "again" {
+		t.Errorf("user parts = %+v, want functionResponse followed by text", parts)
+	}
+
+	// 函数调用没有 ID，按它在回复中的位置生成
+	checkSubmitCall(t, msg, "call_1")
+	checkUsage(t, msg, 300, 25)
+	if msg.Content != "Resubmitting." || msg.ResponseMeta.FinishReason != "STOP" {
+		t.Errorf("content = %q, finish reason = %s", msg.Content, msg.ResponseMeta.FinishReason)
+	}
+}
+
+func TestGeminiChatModelResponseSchema(t *testing.T) {
+	server, got := newStubServer(t, http.StatusOK, `{"candidates": [{"content": {"role": "model", "parts": [{"text": "{\"translations\": [\"你好\"]}"}]}, "finishReason": "STOP"}]}`)
+	schemaJSON, err := translationSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chatModel, err := NewChatModel(context.Background(), ProviderGemini, ProviderConfig{APIKey: "gk-test", BaseURL: server.URL, Model: "gemini-2.5-flash", ResponseSchema: schemaJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chatModel.Generate(context.Background(), toolRoundTrip()[:2]); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	var req geminiRequest
+	if err := json.Unmarshal(got.Body, &req); err != nil {
+		t.Fatalf("request body: %v", err)
+	}
+	if req.GenerationConfig == nil || req.GenerationConfig.ResponseMimeType != "application/json" || len(req.GenerationConfig.ResponseSchema) == 0 {
+		t.Errorf("generationConfig = %+v, want application/json with schema", req.GenerationConfig)
+	}
+}
+
+func TestGeminiChatModelAPIError(t *testing.T) {
+	server, _ := newStubServer(t, http.StatusForbidden, `{"error": {"code": 403, "message": "API key not valid. Please pass a valid API key.", "status": "PERMISSION_DENIED"}}`)
+	chatModel := newBoundModel(t, ProviderGemini, ProviderConfig{APIKey: "bad", BaseURL: server.URL, Model: "gemini-2.5-flash"})
+
+	_, err := chatModel.Generate(context.Background(), toolRoundTrip())
+	checkAPIError(t, err, ProviderGemini, http.StatusForbidden, "API key not valid. Please pass a valid API key.")
+}
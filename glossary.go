@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// GlossaryEntry 是术语表中的一条：原文术语、指定译法和可选备注
+type GlossaryEntry struct {
+	Source string
+	Target string
+	Note   string
+
+	pattern *regexp.Regexp
+}
+
+type Glossary struct {
+	Entries []*GlossaryEntry
+}
+
+func NewGlossaryEntry(source string, target string, note string) *GlossaryEntry {
+	quoted := regexp.QuoteMeta(source)
+	// 以字母数字开头/结尾的术语按单词边界匹配，避免 "Al" 匹配到 "Also"
+	if isWordRune(source[0]) {
+		quoted = `\b` + quoted
+	}
+	if isWordRune(source[len(source)-1]) {
+		quoted += `\b`
+	}
+	return &GlossaryEntry{
+		Source:  source,
+		Target:  target,
+		Note:    note,
+		pattern: regexp.MustCompile(`(?i)` + quoted),
+	}
+}
+
+func isWordRune(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// LoadGlossary 读取 CSV 术语表，每行为：原文术语,译法[,备注]。
+// 以 # 开头的行为注释，首行为 source,target 表头时会被忽略
+func LoadGlossary(path string) (*Glossary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open glossary: %w", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	glossary := &Glossary{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read glossary %s: %w", path, err)
+		}
+		if line == 1 && len(record) >= 2 && strings.EqualFold(record[0], "source") && strings.EqualFold(record[1], "target") {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("glossary %s line %d: expected at least 2 columns", path, line)
+		}
+
+		source := strings.TrimSpace(record[0])
+		target := strings.TrimSpace(record[1])
+		if source == "" || target == "" {
+			continue
+		}
+		note := ""
+		if len(record) > 2 {
+			note = strings.TrimSpace(record[2])
+		}
+		glossary.Entries = append(glossary.Entries, NewGlossaryEntry(source, target, note))
+	}
+	return glossary, nil
+}
+
+// SaveGlossary 把术语表写成 LoadGlossary 可以读取的 CSV 文件，便于手工修改后重复使用
+func SaveGlossary(path string, glossary *Glossary) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create glossary: %w", err)
+	}
+
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"source", "target", "note"})
+	for _, entry := range glossary.Entries {
+		writer.Write([]string{entry.Source, entry.Target, entry.Note})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write glossary %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write glossary %s: %w", path, err)
+	}
+	return nil
+}
+
+// Lookup 按原文术语（不区分大小写）查找条目
+func (g *Glossary) Lookup(source string) (*GlossaryEntry, bool) {
+	if g == nil {
+		return nil, false
+	}
+	for _, entry := range g.Entries {
+		if strings.EqualFold(entry.Source, source) {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// Match 返回在任一文本中出现过的术语
+func (g *Glossary) Match(texts []string) []*GlossaryEntry {
+	if g == nil {
+		return nil
+	}
+
+	var matched []*GlossaryEntry
+	for _, entry := range g.Entries {
+		for _, text := range texts {
+			if entry.pattern.MatchString(text) {
+				matched = append(matched, entry)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// glossaryViolations 检查每条原文中出现的术语是否在对应译文中使用了指定译法，返回可直接反馈给模型的问题描述
+func glossaryViolations(entries []*GlossaryEntry, sources []string, translations []string) []string {
+	var violations []string
+	for i, source := range sources {
+		if i >= len(translations) {
+			break
+		}
+		translation := strings.ToLower(translations[i])
+		for _, entry := range entries {
+			if entry.pattern.MatchString(source) && !strings.Contains(translation, strings.ToLower(entry.Target)) {
+				violations = append(violations, fmt.Sprintf("第 %d 条译文中 %q 必须译为 %q", i+1, entry.Source, entry.Target))
+			}
+		}
+	}
+	return violations
+}
+
+// formatGlossaryPrompt 把术语列表格式化为提示词的一部分
+func formatGlossaryPrompt(entries []*GlossaryEntry) string {
+	var builder strings.Builder
+	for _, entry := range entries {
+		builder.WriteString(fmt.Sprintf("- %s => %s", entry.Source, entry.Target))
+		if entry.Note != "" {
+			builder.WriteString(fmt.Sprintf("（%s）", entry.Note))
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// parseGlossarySpec 解析 --glossary 参数，格式为 path 或 lang=path，不带语言的术语表适用于所有目标语言
+func parseGlossarySpec(spec string) (string, string) {
+	if lang, path, ok := strings.Cut(spec, "="); ok && !strings.ContainsAny(lang, `/\`) {
+		return lang, path
+	}
+	return "", spec
+}
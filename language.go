@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+)
+
+type Language struct {
+	Code      string
+	Name      string
+	StyleName string
+}
+
+var knownLanguages = []Language{
+	{Code: "en", Name: "英文", StyleName: "English"},
+	{Code: "zh", Name: "中文", StyleName: "Chinese"},
+	{Code: "zh-Hans", Name: "简体中文", StyleName: "ChineseSimplified"},
+	{Code: "zh-Hant", Name: "繁体中文", StyleName: "ChineseTraditional"},
+	{Code: "ja", Name: "日文", StyleName: "Japanese"},
+	{Code: "ko", Name: "韩文", StyleName: "Korean"},
+	{Code: "es", Name: "西班牙文", StyleName: "Spanish"},
+	{Code: "fr", Name: "法文", StyleName: "French"},
+	{Code: "de", Name: "德文", StyleName: "German"},
+	{Code: "it", Name: "意大利文", StyleName: "Italian"},
+	{Code: "pt", Name: "葡萄牙文", StyleName: "Portuguese"},
+	{Code: "ru", Name: "俄文", StyleName: "Russian"},
+	{Code: "ar", Name: "阿拉伯文", StyleName: "Arabic"},
+	{Code: "th", Name: "泰文", StyleName: "Thai"},
+	{Code: "vi", Name: "越南文", StyleName: "Vietnamese"},
+}
+
+// LookupLanguage 根据语言代码查找语言信息，未知代码直接使用代码本身作为名称
+func LookupLanguage(code string) Language {
+	for _, lang := range knownLanguages {
+		if strings.EqualFold(lang.Code, code) {
+			return lang
+		}
+	}
+	return Language{Code: code, Name: code, StyleName: code}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// 日志语言
+const (
+	LogLangZh = "zh"
+	LogLangEn = "en"
+)
+
+// LogOptions 控制日志的级别、格式、输出位置、语言和脱敏
+type LogOptions struct {
+	// Level 为 debug、info、warn 或 error
+	Level string
+	// Format 为 text 或 json
+	Format string
+	// File 不为空时日志追加写入该文件，否则写入 stderr
+	File string
+	// Lang 为日志消息的语言：zh 或 en
+	Lang string
+	// Redact 为 true 时不记录字幕原文、译文和模型输出，只记录其长度
+	Redact bool
+}
+
+var (
+	logLang   = LogLangZh
+	logRedact bool
+	logFile   *os.File
+)
+
+// secretLogKeys 中的属性总是脱敏，避免 API Key 和认证信息进入日志
+var secretLogKeys = map[string]bool{
+	"api_key":       true,
+	"auth_token":    true,
+	"authorization": true,
+}
+
+// setupLogging 按 opts 创建 slog 处理器并设为默认 logger，标准库 log 的输出也会经过它。
+// out 不为 nil 时代替 stderr 作为输出（设置了 opts.File 时仍写入文件）
+func setupLogging(opts LogOptions, out io.Writer) error {
+	var level slog.Level
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return fmt.Errorf("unknown log level %q, supported: debug, info, warn, error", opts.Level)
+		}
+	}
+
+	switch opts.Lang {
+	case "", LogLangZh:
+		logLang = LogLangZh
+	case LogLangEn:
+		logLang = LogLangEn
+	default:
+		return fmt.Errorf("unknown log language %q, supported: zh, en", opts.Lang)
+	}
+	logRedact = opts.Redact
+
+	w := out
+	if opts.File != "" {
+		if logFile == nil {
+			f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return fmt.Errorf("failed to open log file: %w", err)
+			}
+			logFile = f
+		}
+		w = logFile
+	} else if w == nil {
+		w = os.Stderr
+	}
+
+	handlerOpts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if secretLogKeys[strings.ToLower(a.Key)] {
+				return slog.String(a.Key, "[redacted]")
+			}
+			return a
+		},
+	}
+	var handler slog.Handler
+	switch opts.Format {
+	case "", "text":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		return fmt.Errorf("unknown log format %q, supported: text, json", opts.Format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// logComponent 是日志的来源模块，记录为 component 属性。
+// 每条日志同时给出中文和英文消息，按 --log-lang 选择其中之一
+type logComponent string
+
+const (
+	logMain       logComponent = "main"
+	logAgent      logComponent = "agent"
+	logSummary    logComponent = "summary"
+	logTranslate  logComponent = "translate"
+	logTerms      logComponent = "terms"
+	logReview     logComponent = "review"
+	logCheckpoint logComponent = "checkpoint"
+	logBatch      logComponent = "batch"
+	logServe      logComponent = "serve"
+)
+
+func (c logComponent) Debug(zh, en string, args ...any) { c.log(slog.LevelDebug, zh, en, args...) }
+func (c logComponent) Info(zh, en string, args ...any)  { c.log(slog.LevelInfo, zh, en, args...) }
+func (c logComponent) Warn(zh, en string, args ...any)  { c.log(slog.LevelWarn, zh, en, args...) }
+func (c logComponent) Error(zh, en string, args ...any) { c.log(slog.LevelError, zh, en, args...) }
+
+func (c logComponent) log(level slog.Level, zh, en string, args ...any) {
+	ctx := context.Background()
+	logger := slog.Default()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	msg := zh
+	if logLang == LogLangEn {
+		msg = en
+	}
+	logger.Log(ctx, level, msg, append([]any{"component", string(c)}, args...)...)
+}
+
+// sensitive 标记可能包含字幕原文、译文或模型输出的日志值，开启脱敏时只记录其长度
+func sensitive(v any) slog.LogValuer {
+	return sensitiveValue{v}
+}
+
+type sensitiveValue struct {
+	v any
+}
+
+func (s sensitiveValue) LogValue() slog.Value {
+	if !logRedact {
+		return slog.AnyValue(s.v)
+	}
+	switch v := s.v.(type) {
+	case string:
+		return slog.StringValue(fmt.Sprintf("[redacted %d chars]", len([]rune(v))))
+	case []string:
+		return slog.StringValue(fmt.Sprintf("[redacted %d items]", len(v)))
+	default:
+		return slog.StringValue("[redacted]")
+	}
+}
@@ -3,40 +3,85 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	apiKey       string
-	baseURL      string
-	modelName    string
-	inputFile    string
-	outputFile   string
-	outputFormat string
+	providerName     string
+	apiKey           string
+	baseURL          string
+	modelName        string
+	inputFile        string
+	outputFile       string
+	outputFormat     string
+	sourceLang       string
+	targetLangs      []string
+	combine          bool
+	concurrency      int
+	rpm              int
+	tpm              int
+	apiRetries       int
+	noCache          bool
+	cacheDir         string
+	resume           bool
+	configFile       string
+	profile          string
+	assStyle         ASSStyle
+	assMode          string
+	glossaries       []string
+	extractTerms     bool
+	review           bool
+	reviewModel      string
+	fallbackModels   []string
+	responseMode     string
+	reviewFix        bool
+	maxFallbackRatio float64
+	modelPrices      map[string]ModelPrice
+	grouping         GroupOptions
+	eventsFormat     string
+	noProgress       bool
+	logOptions       LogOptions
+	dryRun           bool
 )
 
 func main() {
 	rootCmd := &cobra.Command{
 		Use:   "subai",
 		Short: "Subtitle translation agent powered by Eino",
-		Long:  "A subtitle translation agent that translates English subtitles to Chinese-English bilingual subtitles using the Eino framework.",
+		Long:  "A subtitle translation agent that translates subtitles into bilingual subtitles for any source/target language pair using the Eino framework.",
 		Run:   run,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyConfig(cmd); err != nil {
+				return err
+			}
+			return setupLogging(logOptions, nil)
+		},
 	}
 
-	rootCmd.Flags().StringVarP(&apiKey, "api-key", "k", "", "OpenAI API key (required)")
-	rootCmd.Flags().StringVarP(&baseURL, "base-url", "u", "", "Custom base URL for OpenAI API")
-	rootCmd.Flags().StringVarP(&modelName, "model", "m", "gpt-3.5-turbo", "Model name to use for translation")
+	addTranslateFlags(rootCmd)
+	addProgressFlags(rootCmd)
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Parse and group the input, then print the estimated token usage and cost without calling the model")
 	rootCmd.Flags().StringVarP(&inputFile, "input", "i", "", "Input subtitle file path (required)")
 	rootCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output subtitle file path (required)")
-	rootCmd.Flags().StringVarP(&outputFormat, "format", "f", "srt", "Output format (srt or ass)")
+	rootCmd.Flags().StringVarP(&outputFormat, "format", "f", "srt", "Output format (srt, ass or vtt)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file path (default: $XDG_CONFIG_HOME/subai/config.yaml or ~/.config/subai/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Named profile from the config file to use")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Translation cache directory (default: <user cache dir>/subai/translations)")
+	rootCmd.PersistentFlags().StringVar(&logOptions.Level, "log-level", "info", "Log level: debug, info, warn or error (debug includes request and response payloads)")
+	rootCmd.PersistentFlags().StringVar(&logOptions.Format, "log-format", "text", "Log format: text or json")
+	rootCmd.PersistentFlags().StringVar(&logOptions.File, "log-file", "", "Append logs to this file instead of stderr")
+	rootCmd.PersistentFlags().StringVar(&logOptions.Lang, "log-lang", LogLangZh, "Language of log messages: zh or en")
+	rootCmd.PersistentFlags().BoolVar(&logOptions.Redact, "redact-logs", false, "Never log subtitle text, translations or model output, only their lengths")
 
-	rootCmd.MarkFlagRequired("api-key")
 	rootCmd.MarkFlagRequired("input")
 	rootCmd.MarkFlagRequired("output")
 
+	rootCmd.AddCommand(newCacheCmd(), newBatchCmd(), newServeCmd(), newRetryFailedCmd())
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -44,38 +89,241 @@ func main() {
 }
 
 func run(cmd *cobra.Command, args []string) {
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
-	log.Printf("[Main] SubAI 字幕翻译 Agent 启动")
-	log.Printf("[Main] 配置 - 模型: %s, 输入: %s, 输出: %s, 格式: %s", modelName, inputFile, outputFile, outputFormat)
+	input := AgentInput{
+		SubtitlePath:     inputFile,
+		OutputPath:       outputFile,
+		OutputFormat:     outputFormat,
+		SourceLang:       sourceLang,
+		TargetLangs:      targetLangs,
+		CombineOutput:    combine,
+		ASSStyle:         assStyle,
+		ASSMode:          assMode,
+		Glossaries:       glossaries,
+		Resume:           resume,
+		ExtractTerms:     extractTerms,
+		Review:           review || reviewFix,
+		ReviewFix:        reviewFix,
+		MaxFallbackRatio: maxFallbackRatio,
+		Grouping:         grouping,
+	}
+
+	if dryRun {
+		tracker := NewUsageTracker()
+		estimate, err := EstimateUsage(tracker, input, dryRunConfig())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		PrintDryRunEstimate(os.Stdout, []*DryRunEstimate{estimate}, tracker.Summary(modelPrices))
+		return
+	}
+
+	if err := requireAPIKey(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	onEvent, err := newEventHandler()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	logMain.Info("SubAI 字幕翻译 Agent 启动", "SubAI subtitle translation agent starting",
+		"model", modelName, "input", inputFile, "output", outputFile, "format", outputFormat,
+		"source_lang", sourceLang, "target_langs", strings.Join(targetLangs, ","))
 
 	ctx := context.Background()
 
-	agent, err := NewSubtitleAgent(ctx, apiKey, baseURL, modelName)
+	agent, err := NewSubtitleAgent(ctx, newTranslatorConfig())
 	if err != nil {
-		log.Printf("[Main] 创建 Agent 失败: %v", err)
+		logMain.Error("创建 Agent 失败", "failed to create agent", "err", err)
 		fmt.Fprintf(os.Stderr, "Failed to create agent: %v\n", err)
 		os.Exit(1)
 	}
 
-	input := AgentInput{
-		SubtitlePath: inputFile,
-		OutputPath:   outputFile,
-		OutputFormat: outputFormat,
-	}
+	input.OnEvent = onEvent
 
 	output, err := agent.Run(ctx, input)
 	if err != nil {
-		log.Printf("[Main] 运行失败: %v", err)
+		logMain.Error("运行失败", "run failed", "err", err)
 		fmt.Fprintf(os.Stderr, "Failed to run agent: %v\n", err)
 		os.Exit(1)
 	}
 
+	// 输出 JSON 事件流时 stdout 只保留事件，用量和结果已包含在 finished 事件中
+	if eventsFormat == "" && output.Report != nil && output.Report.Usage != nil {
+		PrintUsageSummary(os.Stdout, output.Report.Usage)
+	}
+
 	if output.Success {
-		log.Printf("[Main] 完成: %s", output.Message)
-		fmt.Println(output.Message)
+		logMain.Info("完成", "done", "message", output.Message)
+		if eventsFormat == "" {
+			fmt.Println(output.Message)
+		}
 	} else {
-		log.Printf("[Main] 翻译失败: %s", output.Message)
+		logMain.Error("翻译失败", "translation failed", "message", output.Message)
 		fmt.Fprintf(os.Stderr, "Translation failed: %s\n", output.Message)
 		os.Exit(1)
 	}
 }
+
+// addTranslateFlags 注册 run、batch 和 serve 共用的翻译参数
+func addTranslateFlags(cmd *cobra.Command) {
+	addModelFlags(cmd)
+	flags := cmd.Flags()
+	flags.StringVarP(&sourceLang, "source-lang", "s", "en", "Source language code of the input subtitle (e.g. en, ja, es)")
+	flags.StringSliceVarP(&targetLangs, "target-lang", "t", []string{"zh"}, "Target language codes to translate into, comma separated (e.g. zh-Hans,zh-Hant,ja)")
+	flags.BoolVar(&combine, "combine", false, "Write all target languages into one output file instead of one file per language")
+	flags.BoolVar(&resume, "resume", false, "Resume an interrupted run from the checkpoint journal next to the output file")
+	flags.BoolVar(&extractTerms, "extract-terms", false, "Extract recurring names and terms before translating and save them as an editable glossary next to the output (reused on later runs)")
+	flags.BoolVar(&review, "review", false, "Review the translation after it finishes and write a report of flagged cues next to the output")
+	flags.StringVar(&reviewModel, "review-model", "", "Model used for the review pass (default: same as --model)")
+	flags.BoolVar(&reviewFix, "review-fix", false, "Apply the fixes suggested by the review pass instead of only reporting them")
+	flags.Float64Var(&grouping.MaxGapSeconds, "group-gap", DefaultGroupOptions.MaxGapSeconds, "Subtitles separated by at most this many seconds are translated together as one group")
+	flags.IntVar(&grouping.MaxCues, "group-max-cues", DefaultGroupOptions.MaxCues, "Maximum number of cues per group; longer dialogue is split at sentence boundaries (0 = unlimited)")
+	flags.IntVar(&grouping.MaxTokens, "group-max-tokens", DefaultGroupOptions.MaxTokens, "Maximum estimated source tokens per group (0 = default for the model, e.g. 1500 for unknown models; -1 = unlimited)")
+	flags.Float64Var(&maxFallbackRatio, "max-fallback-ratio", 1, "Fail without writing output when more than this fraction of cues fell back to the source text (0 = fail on any fallback)")
+	flags.StringSliceVar(&glossaries, "glossary", nil, "Glossary CSV file (source term, target term, optional note); use lang=path to limit it to one target language")
+	flags.StringVar(&assMode, "ass-mode", ASSModeRebuild, "How ASS input is written back: rebuild (new bilingual script), inline (translation added to each original line) or companion (extra translated line per original line, original styles kept)")
+	flags.StringVar(&assStyle.FontName, "ass-font", DefaultASSStyle.FontName, "Font name used in ASS output")
+	flags.IntVar(&assStyle.TranslationSize, "ass-translation-size", DefaultASSStyle.TranslationSize, "Font size of translated lines in ASS output")
+	flags.StringVar(&assStyle.TranslationColour, "ass-translation-colour", DefaultASSStyle.TranslationColour, "Colour of translated lines in ASS output (&HAABBGGRR)")
+	flags.IntVar(&assStyle.OriginalSize, "ass-original-size", DefaultASSStyle.OriginalSize, "Font size of original lines in ASS output")
+	flags.StringVar(&assStyle.OriginalColour, "ass-original-colour", DefaultASSStyle.OriginalColour, "Colour of original lines in ASS output (&HAABBGGRR)")
+}
+
+// addModelFlags 注册模型服务、模型和请求相关的参数，retry-failed 只使用这些参数，其余参数沿用原来的运行
+func addModelFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&providerName, "provider", ProviderOpenAI, "Model provider: "+strings.Join(providerNames(), ", ")+" (openai also covers any OpenAI-compatible API)")
+	flags.StringVarP(&apiKey, "api-key", "k", "", "API key (or SUBAI_API_KEY, or the provider's own variable such as OPENAI_API_KEY / ANTHROPIC_API_KEY / GEMINI_API_KEY)")
+	flags.StringVarP(&baseURL, "base-url", "u", "", "Custom base URL for the provider API (e.g. http://localhost:11434 for Ollama)")
+	flags.StringVarP(&modelName, "model", "m", "gpt-3.5-turbo", "Model name to use for translation")
+	flags.StringVar(&responseMode, "response-mode", ResponseModeTool, "How the model returns translations: "+strings.Join(responseModes(), ", ")+" (json-schema uses structured output for models without tool calling, text parses JSON from the reply)")
+	flags.StringSliceVar(&fallbackModels, "fallback-model", nil, "Ordered fallback models tried when a group fails on the previous one, as [provider:]model[@base-url] (e.g. gpt-4o,anthropic:claude-sonnet-4-5,ollama:qwen2.5:14b@http://gpu-box:11434)")
+	flags.IntVarP(&concurrency, "concurrency", "c", 1, "Number of subtitle groups translated concurrently; with more than 1, a group's prompt carries the previous group's source lines but not its translation (unless restored from a checkpoint), so 1 gives the most consistent context")
+	flags.IntVar(&rpm, "rpm", 0, "Maximum requests per minute sent to the model (0 = unlimited)")
+	flags.IntVar(&tpm, "tpm", 0, "Maximum estimated tokens per minute sent to the model (0 = unlimited)")
+	flags.IntVar(&apiRetries, "api-retries", DefaultAPIRetries, "Times a request is retried after a transient API error (429, 5xx, connection reset) with exponential backoff honouring Retry-After; separate from translation validation retries (0 = no retry)")
+	flags.BoolVar(&noCache, "no-cache", false, "Disable the on-disk translation cache")
+}
+
+// addProgressFlags 注册 run 和 batch 共用的进度显示参数
+func addProgressFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&eventsFormat, "events", "", "Write a machine-readable event stream to stdout instead of the usual output; supported: json (one JSON object per line)")
+	flags.BoolVar(&noProgress, "no-progress", false, "Disable the progress line shown when stderr is a terminal")
+}
+
+// newEventHandler 根据 --events 和 --no-progress 返回事件处理函数：输出 JSON 事件流，
+// 或者在终端中显示进度行，都不需要时返回 nil
+func newEventHandler() (EventFunc, error) {
+	switch eventsFormat {
+	case "":
+	case "json":
+		return NewJSONEventWriter(os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unknown event format %q, supported: json", eventsFormat)
+	}
+	if noProgress || !isTerminal(os.Stderr) {
+		return nil, nil
+	}
+	display := NewProgressDisplay(os.Stderr)
+	if err := setupLogging(logOptions, display); err != nil {
+		return nil, err
+	}
+	return display.HandleEvent, nil
+}
+
+// dryRunConfig 返回 --dry-run 估算用到的模型设置，不打开翻译缓存
+func dryRunConfig() *TranslatorConfig {
+	return &TranslatorConfig{
+		Provider:     providerName,
+		Model:        modelName,
+		ReviewModel:  reviewModel,
+		ResponseMode: responseMode,
+		Concurrency:  concurrency,
+	}
+}
+
+// newTranslatorConfig 根据命令行参数构建翻译器配置
+func newTranslatorConfig() *TranslatorConfig {
+	var cache *TranslationCache
+	if !noCache {
+		var err error
+		cache, err = NewTranslationCache(cacheDir)
+		if err != nil {
+			logMain.Warn("打开翻译缓存失败，将不使用缓存", "failed to open translation cache, continuing without it", "err", err)
+		}
+	}
+
+	return &TranslatorConfig{
+		Provider:          providerName,
+		APIKey:            apiKey,
+		BaseURL:           baseURL,
+		Model:             modelName,
+		ReviewModel:       reviewModel,
+		FallbackModels:    fallbackModels,
+		ResponseMode:      responseMode,
+		Concurrency:       concurrency,
+		RequestsPerMinute: rpm,
+		TokensPerMinute:   tpm,
+		APIRetries:        apiRetries,
+		Cache:             cache,
+		Prices:            modelPrices,
+	}
+}
+
+func newCacheCmd() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect or prune the on-disk translation cache",
+	}
+
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show translation cache statistics",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := NewTranslationCache(cacheDir)
+			if err != nil {
+				return err
+			}
+			stats, err := cache.Stats()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Directory: %s\n", stats.Dir)
+			fmt.Printf("Entries:   %d\n", stats.Entries)
+			fmt.Printf("Size:      %d bytes\n", stats.Bytes)
+			if stats.Entries > 0 {
+				fmt.Printf("Oldest:    %s\n", stats.Oldest.Format(time.DateTime))
+				fmt.Printf("Newest:    %s\n", stats.Newest.Format(time.DateTime))
+			}
+			return nil
+		},
+	}
+
+	var olderThan time.Duration
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove cache entries not used within --older-than (0 removes everything)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := NewTranslationCache(cacheDir)
+			if err != nil {
+				return err
+			}
+			removed, freed, err := cache.Prune(olderThan)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed %d entries, freed %d bytes\n", removed, freed)
+			return nil
+		},
+	}
+	pruneCmd.Flags().DurationVar(&olderThan, "older-than", 30*24*time.Hour, "Remove entries not used for at least this long")
+
+	cacheCmd.AddCommand(statsCmd, pruneCmd)
+	return cacheCmd
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+const defaultOllamaBaseURL = "http://localhost:11434"
+
+// ollamaChatModel 使用 Ollama 原生的 /api/chat 接口，适合本地运行的模型
+type ollamaChatModel struct {
+	config ProviderConfig
+	tools  []ollamaTool
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+	// Format 是要求模型输出的 JSON Schema
+	Format json.RawMessage `json:"format,omitempty"`
+	Stream bool            `json:"stream"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+func newOllamaChatModel(ctx context.Context, config ProviderConfig) (model.ToolCallingChatModel, error) {
+	if config.BaseURL == "" {
+		config.BaseURL = defaultOllamaBaseURL
+	}
+	return &ollamaChatModel{config: config}, nil
+}
+
+func (m *ollamaChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound := &ollamaChatModel{config: m.config}
+	for _, tool := range tools {
+		params, err := toolParameters(tool)
+		if err != nil {
+			return nil, err
+		}
+		var t ollamaTool
+		t.Type = "function"
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Desc
+		t.Function.Parameters = params
+		bound.tools = append(bound.tools, t)
+	}
+	return bound, nil
+}
+
+func (m *ollamaChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	names := toolCallNames(input)
+	req := ollamaChatRequest{Model: m.config.Model, Tools: m.tools, Format: m.config.ResponseSchema}
+	for _, msg := range input {
+		om := ollamaMessage{Role: string(msg.Role), Content: msg.Content}
+		for _, call := range msg.ToolCalls {
+			var tc ollamaToolCall
+			tc.Function.Name = call.Function.Name
+			tc.Function.Arguments = toolArguments(call.Function.Arguments)
+			om.ToolCalls = append(om.ToolCalls, tc)
+		}
+		if msg.Role == schema.Tool {
+			om.ToolName = names[msg.ToolCallID]
+		}
+		req.Messages = append(req.Messages, om)
+	}
+
+	var resp ollamaChatResponse
+	if err := postJSON(ctx, ProviderOllama, joinURL(m.config.BaseURL, "/api/chat"), nil, req, &resp); err != nil {
+		return nil, err
+	}
+
+	// Ollama 的工具调用没有 ID，按顺序生成一个
+	msg := schema.AssistantMessage(resp.Message.Content, nil)
+	for i, call := range resp.Message.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, schema.ToolCall{
+			ID:       fmt.Sprintf("call_%d", i),
+			Type:     "function",
+			Function: schema.FunctionCall{Name: call.Function.Name, Arguments: string(call.Function.Arguments)},
+		})
+	}
+	msg.ResponseMeta = &schema.ResponseMeta{
+		FinishReason: resp.DoneReason,
+		Usage: &schema.TokenUsage{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+	}
+	return msg, nil
+}
+
+func (m *ollamaChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return singleMessageStream(m.Generate(ctx, input, opts...))
+}
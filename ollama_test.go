@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestOllamaChatModelGenerate(t *testing.T) {
+	server, got := newStubServer(t, http.StatusOK, `{
+		"model": "qwen2.5:14b",
+		"message": {"role": "assistant", "content": "", "tool_calls": [
+			{"function": {"name": "submit_translation", "arguments": {"translations": ["你好"]}}}
+		]},
+		"done": true,
+		"done_reason": "stop",
+		"prompt_eval_count": 120,
+		"eval_count": 30
+	}`)
+	chatModel := newBoundModel(t, ProviderOllama, ProviderConfig{BaseURL: server.URL, Model: "qwen2.5:14b"})
+
+	msg, err := chatModel.Generate(context.Background(), toolRoundTrip())
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if got.Path != "/api/chat" {
+		t.Errorf("path = %s, want /api/chat", got.Path)
+	}
+	var req ollamaChatRequest
+	if err := json.Unmarshal(got.Body, &req); err != nil {
+		t.Fatalf("request body: %v", err)
+	}
+	if req.Model != "qwen2.5:14b" || req.Stream {
+		t.Errorf("model = %s, stream = %v, want qwen2.5:14b without streaming", req.Model, req.Stream)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Type != "function" || req.Tools[0].Function.Name != "submit_translation" {
+		t.Errorf("tools = %+v, want submit_translation", req.Tools)
+	}
+	roles := make([]string, len(req.Messages))
+	for i, m := range req.Messages {
+		roles[i] = m.Role
+	}
+	if want := []string{"system", "user", "assistant", "tool", "user"}; !slices.Equal(roles, want) {
+		t.Fatalf("roles = %v, want %v", roles, want)
+	}
+	assistant := req.Messages[2]
+	if len(assistant.ToolCalls) != 1 || assistant.ToolCalls[0].Function.Name != "submit_translation" ||
+		string(assistant.ToolCalls[0].Function.Arguments) != `{"translations":["你好"]}` {
+		t.Errorf("assistant tool calls = %+v", assistant.ToolCalls)
+	}
+	// Ollama 按工具名关联工具结果
+	if tool := req.Messages[3]; tool.ToolName != "submit_translation" || tool.Content != "length mismatch" {
+		t.Errorf("tool message = %+v, want submit_translation result", tool)
+	}
+
+	checkSubmitCall(t, msg, "call_0")
+	checkUsage(t, msg, 120, 30)
+	if msg.ResponseMeta.FinishReason != "stop" {
+		t.Errorf("finish reason = %s, want stop", msg.ResponseMeta.FinishReason)
+	}
+}
+
+func TestOllamaChatModelResponseSchema(t *testing.T) {
+	server, got := newStubServer(t, http.StatusOK, `{"message": {"role": "assistant", "content": "{\"translations\": [\"你好\"]}"}, "done": true}`)
+	schemaJSON, err := translationSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chatModel, err := NewChatModel(context.Background(), ProviderOllama, ProviderConfig{BaseURL: server.URL, Model: "gemma2", ResponseSchema: schemaJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := chatModel.Generate(context.Background(), toolRoundTrip()[:2])
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	var req ollamaChatRequest
+	if err := json.Unmarshal(got.Body, &req); err != nil {
+		t.Fatalf("request body: %v", err)
+	}
+	if len(req.Format) == 0 || len(req.Tools) != 0 {
+		t.Errorf("format = %s, tools = %d, want schema without tools", req.Format, len(req.Tools))
+	}
+	if msg.Content != `{"translations": ["你好"]}` {
+		t.Errorf("content = %q", msg.Content)
+	}
+}
+
+func TestOllamaChatModelAPIError(t *testing.T) {
+	server, _ := newStubServer(t, http.StatusNotFound, `{"error": "model \"missing\" not found, try pulling it first"}`)
+	chatModel := newBoundModel(t, ProviderOllama, ProviderConfig{BaseURL: server.URL, Model: "missing"})
+
+	_, err := chatModel.Generate(context.Background(), toolRoundTrip())
+	checkAPIError(t, err, ProviderOllama, http.StatusNotFound, `model "missing" not found, try pulling it first`)
+}
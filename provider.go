@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// 支持的模型服务
+const (
+	ProviderOpenAI    = "openai"
+	ProviderOllama    = "ollama"
+	ProviderAnthropic = "anthropic"
+	ProviderGemini    = "gemini"
+)
+
+// ProviderConfig 是创建聊天模型所需的参数，BaseURL 为空时使用模型服务的默认地址
+type ProviderConfig struct {
+	APIKey  string
+	BaseURL string
+	Model   string
+	// ResponseSchema 不为空时要求模型按该 JSON Schema 输出结构化结果，用于 json-schema 响应模式
+	ResponseSchema json.RawMessage
+}
+
+// provider 描述一种模型服务的接口
+type provider struct {
+	// KeyEnv 和 BaseURLEnv 是除 SUBAI_API_KEY 和 SUBAI_BASE_URL 之外也会读取的环境变量
+	KeyEnv     string
+	BaseURLEnv string
+	// RequiresKey 为 false 表示可以不提供 API Key，例如本地运行的 Ollama
+	RequiresKey bool
+	New         func(ctx context.Context, config ProviderConfig) (model.ToolCallingChatModel, error)
+}
+
+var providers = map[string]provider{
+	ProviderOpenAI:    {KeyEnv: "OPENAI_API_KEY", BaseURLEnv: "OPENAI_BASE_URL", RequiresKey: true, New: newOpenAIChatModel},
+	ProviderOllama:    {RequiresKey: false, New: newOllamaChatModel},
+	ProviderAnthropic: {KeyEnv: "ANTHROPIC_API_KEY", BaseURLEnv: "ANTHROPIC_BASE_URL", RequiresKey: true, New: newAnthropicChatModel},
+	ProviderGemini:    {KeyEnv: "GEMINI_API_KEY", RequiresKey: true, New: newGeminiChatModel},
+}
+
+// providerNames 返回所有支持的模型服务名称
+func providerNames() []string {
+	return slices.Sorted(maps.Keys(providers))
+}
+
+func lookupProvider(name string) (provider, error) {
+	if name == "" {
+		name = ProviderOpenAI
+	}
+	p, ok := providers[name]
+	if !ok {
+		return provider{}, fmt.Errorf("unknown provider %q, supported: %s", name, strings.Join(providerNames(), ", "))
+	}
+	return p, nil
+}
+
+// NewChatModel 按模型服务名称创建聊天模型，name 为空时使用 OpenAI 兼容接口
+func NewChatModel(ctx context.Context, name string, config ProviderConfig) (model.ToolCallingChatModel, error) {
+	p, err := lookupProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.New(ctx, config)
+}
+
+// ModelSpec 指定一个模型及其所在的模型服务，格式为 [provider:]model[@base-url]，
+// 例如 gpt-4o、anthropic:claude-sonnet-4-5、ollama:qwen2.5:14b@http://gpu-box:11434。
+// Provider 和 BaseURL 为空时沿用主模型的设置
+type ModelSpec struct {
+	Provider string
+	Model    string
+	BaseURL  string
+}
+
+// ParseModelSpec 解析 [provider:]model[@base-url]，只有冒号前是已知的模型服务名称时才把它当作 provider，
+// 因此 qwen2.5:14b 这样带标签的模型名不受影响。同样只有 @ 之后是 http(s):// 开头的绝对地址时才把它当作 base-url，
+// 因此 claude-3-5-sonnet@20240620 这样带版本号的模型名不受影响
+func ParseModelSpec(s string) (ModelSpec, error) {
+	var spec ModelSpec
+	raw := strings.TrimSpace(s)
+	s = raw
+	if name, rest, ok := strings.Cut(s, ":"); ok {
+		if _, known := providers[name]; known {
+			spec.Provider = name
+			s = rest
+		}
+	}
+	for i := strings.Index(s, "@"); i >= 0; {
+		if isBaseURL(s[i+1:]) {
+			spec.BaseURL = s[i+1:]
+			s = s[:i]
+			break
+		}
+		next := strings.Index(s[i+1:], "@")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	spec.Model = s
+	if spec.Model == "" {
+		return ModelSpec{}, fmt.Errorf("invalid model spec %q: model name is empty", raw)
+	}
+	return spec, nil
+}
+
+// isBaseURL 判断 s 是否是 http 或 https 的绝对地址
+func isBaseURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (s ModelSpec) String() string {
+	str := s.Model
+	if s.Provider != "" {
+		str = s.Provider + ":" + str
+	}
+	if s.BaseURL != "" {
+		str += "@" + s.BaseURL
+	}
+	return str
+}
+
+func newOpenAIChatModel(ctx context.Context, config ProviderConfig) (model.ToolCallingChatModel, error) {
+	var responseFormat *openai.ChatCompletionResponseFormat
+	if len(config.ResponseSchema) > 0 {
+		var s jsonschema.Schema
+		if err := json.Unmarshal(config.ResponseSchema, &s); err != nil {
+			return nil, fmt.Errorf("invalid response schema: %w", err)
+		}
+		responseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:       "translation",
+				JSONSchema: &s,
+			},
+		}
+	}
+	chatModel, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		APIKey:         config.APIKey,
+		Model:          config.Model,
+		BaseURL:        config.BaseURL,
+		HTTPClient:     apiHTTPClient,
+		ResponseFormat: responseFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chatModel, nil
+}
+
+// APIError 是模型服务返回的非 2xx 响应
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error: status %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// postJSON 以 JSON 发送请求并解析 JSON 响应，非 2xx 响应返回 *APIError
+func postJSON(ctx context.Context, providerName string, url string, header http.Header, request any, response any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := apiHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{Provider: providerName, StatusCode: resp.StatusCode, Message: apiErrorMessage(data)}
+	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", providerName, err)
+	}
+	return nil
+}
+
+// apiErrorMessage 从错误响应中取出错误信息，兼容 {"error": "..."} 和 {"error": {"message": "..."}} 两种格式
+func apiErrorMessage(data []byte) string {
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && len(body.Error) > 0 {
+		var message string
+		if json.Unmarshal(body.Error, &message) == nil {
+			return message
+		}
+		var detail struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body.Error, &detail) == nil && detail.Message != "" {
+			return detail.Message
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// toolParameters 把工具参数转换为 JSON Schema
+func toolParameters(tool *schema.ToolInfo) (json.RawMessage, error) {
+	s, err := tool.ParamsOneOf.ToJSONSchema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert parameters of tool %s: %w", tool.Name, err)
+	}
+	if s == nil {
+		return json.RawMessage(`{"type":"object","properties":{}}`), nil
+	}
+	return json.Marshal(s)
+}
+
+// toolCallNames 返回消息中每个工具调用 ID 对应的工具名，用于只按名称关联工具结果的接口
+func toolCallNames(messages []*schema.Message) map[string]string {
+	names := make(map[string]string)
+	for _, msg := range messages {
+		for _, call := range msg.ToolCalls {
+			names[call.ID] = call.Function.Name
+		}
+	}
+	return names
+}
+
+// toolArguments 把工具调用参数解析为 JSON 对象，参数为空时返回空对象
+func toolArguments(arguments string) json.RawMessage {
+	if strings.TrimSpace(arguments) == "" || !json.Valid([]byte(arguments)) {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// singleMessageStream 用于只支持非流式接口的模型服务：生成完整回复后作为只有一个元素的流返回
+func singleMessageStream(msg *schema.Message, err error) (*schema.StreamReader[*schema.Message], error) {
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func joinURL(base string, path string) string {
+	return strings.TrimSuffix(base, "/") + path
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// stubRequest 是本地模型服务收到的请求
+type stubRequest struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// newStubServer 启动一个本地模型服务，记录收到的请求并以 status 返回 response
+func newStubServer(t *testing.T, status int, response string) (*httptest.Server, *stubRequest) {
+	t.Helper()
+	got := &stubRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read request body: %v", err)
+		}
+		got.Path = r.URL.Path
+		got.Header = r.Header.Clone()
+		got.Body = body
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, got
+}
+
+// toolRoundTrip 返回一轮完整的工具调用对话：模型调用 submit_translation，收到工具结果后用户要求重新提交
+func toolRoundTrip() []*schema.Message {
+	return []*schema.Message{
+		schema.SystemMessage("sys"),
+		schema.UserMessage(`["Hello"]`),
+		schema.AssistantMessage("", []schema.ToolCall{{
+			ID:       "call_0",
+			Type:     "function",
+			Function: schema.FunctionCall{Name: "submit_translation", Arguments: `{"translations":["你好"]}`},
+		}}),
+		schema.ToolMessage("length mismatch", "call_0"),
+		schema.UserMessage("again"),
+	}
+}
+
+// newBoundModel 创建绑定了 submit_translation 工具的聊天模型
+func newBoundModel(t *testing.T, providerName string, config ProviderConfig) model.ToolCallingChatModel {
+	t.Helper()
+	chatModel, err := NewChatModel(context.Background(), providerName, config)
+	if err != nil {
+		t.Fatalf("NewChatModel: %v", err)
+	}
+	bound, err := chatModel.WithTools([]*schema.ToolInfo{(&SubmitTranslationTool{}).Info()})
+	if err != nil {
+		t.Fatalf("WithTools: %v", err)
+	}
+	return bound
+}
+
+// checkSubmitCall 检查回复中的工具调用被还原为 schema.ToolCall
+func checkSubmitCall(t *testing.T, msg *schema.Message, id string) {
+	t.Helper()
+	if len(msg.ToolCalls) != 1 {
+		t.Fatalf("got %d tool calls, want 1", len(msg.ToolCalls))
+	}
+	call := msg.ToolCalls[0]
+	if call.ID != id || call.Function.Name != "submit_translation" {
+		t.Errorf("tool call = %s %s, want %s submit_translation", call.ID, call.Function.Name, id)
+	}
+	var args SubmitTranslationReq
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+		t.Fatalf("tool call arguments %q: %v", call.Function.Arguments, err)
+	}
+	if len(args.Translations) != 1 || args.Translations[0] != "你好" {
+		t.Errorf("translations = %v, want [你好]", args.Translations)
+	}
+}
+
+// checkUsage 检查 token 用量被映射到 ResponseMeta
+func checkUsage(t *testing.T, msg *schema.Message, prompt int, completion int) {
+	t.Helper()
+	if msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil {
+		t.Fatal("response has no usage")
+	}
+	usage := msg.ResponseMeta.Usage
+	if usage.PromptTokens != prompt || usage.CompletionTokens != completion || usage.TotalTokens != prompt+completion {
+		t.Errorf("usage = %d/%d/%d, want %d/%d/%d", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, prompt, completion, prompt+completion)
+	}
+}
+
+// checkAPIError 检查非 2xx 响应以 *APIError 返回
+func checkAPIError(t *testing.T, err error, providerName string, status int, message string) {
+	t.Helper()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want *APIError", err)
+	}
+	if apiErr.Provider != providerName || apiErr.StatusCode != status || apiErr.Message != message {
+		t.Errorf("APIError = %+v, want %s %d %q", apiErr, providerName, status, message)
+	}
+}
+
+func TestParseModelSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want ModelSpec
+	}{
+		{"gpt-4o", ModelSpec{Model: "gpt-4o"}},
+		{"anthropic:claude-sonnet-4-5", ModelSpec{Provider: "anthropic", Model: "claude-sonnet-4-5"}},
+		{"qwen2.5:14b", ModelSpec{Model: "qwen2.5:14b"}},
+		{"ollama:qwen2.5:14b@http://gpu-box:11434", ModelSpec{Provider: "ollama", Model: "qwen2.5:14b", BaseURL: "http://gpu-box:11434"}},
+		{"anthropic:claude-3-5-sonnet@20240620", ModelSpec{Provider: "anthropic", Model: "claude-3-5-sonnet@20240620"}},
+		{"claude@20240620@https://proxy.example.com/v1", ModelSpec{Model: "claude@20240620", BaseURL: "https://proxy.example.com/v1"}},
+		{"gpt-4o@https://user@proxy.example.com", ModelSpec{Model: "gpt-4o", BaseURL: "https://user@proxy.example.com"}},
+		{"gpt-4o@ftp://example.com", ModelSpec{Model: "gpt-4o@ftp://example.com"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseModelSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParseModelSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseModelSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "anthropic:", "@http://localhost:11434"} {
+		if _, err := ParseModelSpec(spec); err == nil {
+			t.Errorf("ParseModelSpec(%q) succeeded, want error", spec)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const rateLimitWindow = time.Minute
+
+// RateLimiter 使用一分钟滑动窗口同时限制请求数和 token 数，0 表示不限制
+type RateLimiter struct {
+	requestsPerMinute int
+	tokensPerMinute   int
+
+	mu      sync.Mutex
+	entries []*rateLimitEntry
+}
+
+type rateLimitEntry struct {
+	at     time.Time
+	tokens int
+}
+
+func NewRateLimiter(requestsPerMinute int, tokensPerMinute int) *RateLimiter {
+	return &RateLimiter{
+		requestsPerMinute: requestsPerMinute,
+		tokensPerMinute:   tokensPerMinute,
+	}
+}
+
+// Wait 阻塞直到窗口内可以再发送一个消耗 tokens 的请求，或 ctx 被取消。tokens 应包括提示词和预计的输出，
+// 请求完成后用 Settle 按模型服务返回的实际用量修正。不限制时返回的记录为 nil
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) (*rateLimitEntry, error) {
+	if l == nil || (l.requestsPerMinute <= 0 && l.tokensPerMinute <= 0) {
+		return nil, nil
+	}
+
+	for {
+		entry, delay := l.reserve(tokens)
+		if entry != nil {
+			return entry, nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, context.Cause(ctx)
+		case <-timer.C:
+		}
+	}
+}
+
+// Settle 把 Wait 占用的 token 数改为请求实际消耗的 token 数，entry 为 nil 时不做任何事
+func (l *RateLimiter) Settle(entry *rateLimitEntry, tokens int) {
+	if l == nil || entry == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.tokens = tokens
+}
+
+// reserve 尝试占用额度，成功时返回窗口中的记录，否则返回需要等待的时长
+func (l *RateLimiter) reserve(tokens int) (*rateLimitEntry, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	i := 0
+	for i < len(l.entries) && now.Sub(l.entries[i].at) >= rateLimitWindow {
+		i++
+	}
+	l.entries = l.entries[i:]
+
+	used := 0
+	for _, entry := range l.entries {
+		used += entry.tokens
+	}
+
+	requestsOK := l.requestsPerMinute <= 0 || len(l.entries) < l.requestsPerMinute
+	// 单个请求超过每分钟 token 上限时，只要窗口为空就放行，避免永久阻塞
+	tokensOK := l.tokensPerMinute <= 0 || used+tokens <= l.tokensPerMinute || len(l.entries) == 0
+	if requestsOK && tokensOK {
+		entry := &rateLimitEntry{at: now, tokens: tokens}
+		l.entries = append(l.entries, entry)
+		return entry, 0
+	}
+
+	return nil, l.entries[0].at.Add(rateLimitWindow).Sub(now)
+}
+
+// estimateTokens 粗略估算文本的 token 数：ASCII 约 4 个字符一个 token，其他字符（如中日韩文字）每个字符一个 token
+func estimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// completionTokens 按 estimate.go 中对模型输出长度的假设估算请求的输出 token 数，
+// 翻译请求按第一条用户消息（要翻译的原文）估算
+func completionTokens(stage string, messages []*schema.Message) int {
+	switch stage {
+	case StageSummary:
+		return estimatedSummaryTokens
+	case StageTerms:
+		return maxTermsPerChunk * estimatedTermTokens
+	case StageReview:
+		return estimatedReviewTokens
+	}
+	for _, msg := range messages {
+		if msg.Role == schema.User {
+			return int(float64(estimateTokens(msg.Content)) * translationTokenRatio)
+		}
+	}
+	return 0
+}
+
+// messageTokens 估算一组消息的 token 数
+func messageTokens(messages []*schema.Message) int {
+	tokens := 0
+	for _, msg := range messages {
+		tokens += estimateTokens(msg.Content)
+	}
+	return tokens
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestRateLimiterSettle(t *testing.T) {
+	limiter := NewRateLimiter(0, 100)
+	entry, err := limiter.Wait(context.Background(), 80)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	// 预留的 80 个 token 加上 50 个超过每分钟上限，需要等待
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Wait(ctx, 50); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait over the limit = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// 按实际用量修正后额度足够，立即放行
+	limiter.Settle(entry, 30)
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Wait(ctx, 50); err != nil {
+		t.Fatalf("Wait after Settle: %v", err)
+	}
+}
+
+func TestCompletionTokens(t *testing.T) {
+	translate := []*schema.Message{
+		schema.SystemMessage("system prompt that is not translated"),
+		schema.UserMessage(`["Hello, there.","How are you?"]`),
+	}
+	if got, want := completionTokens(StageTranslate, translate), int(float64(estimateTokens(translate[1].Content))*translationTokenRatio); got != want {
+		t.Errorf("completionTokens(translate) = %d, want %d", got, want)
+	}
+	if got := completionTokens(StageSummary, translate); got != estimatedSummaryTokens {
+		t.Errorf("completionTokens(summary) = %d, want %d", got, estimatedSummaryTokens)
+	}
+}
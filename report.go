@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// GroupMethodToolCall 译文通过 submit_translation 工具调用提交
+	GroupMethodToolCall = "tool_call"
+	// GroupMethodContent 模型没有调用工具，译文从响应内容中解析
+	GroupMethodContent = "content"
+	// GroupMethodJSONSchema 译文是模型按 JSON Schema 输出的结构化结果
+	GroupMethodJSONSchema = "json_schema"
+	// GroupMethodCache 译文来自翻译缓存
+	GroupMethodCache = "cache"
+	// GroupMethodCheckpoint 译文来自断点日志
+	GroupMethodCheckpoint = "checkpoint"
+)
+
+// GroupReport 记录一个分组在某个目标语言下的翻译过程，Cues 和 FallbackCues 为字幕序号（从 1 开始）
+type GroupReport struct {
+	TargetLang string `json:"target_lang"`
+	Group      int    `json:"group"`
+	Cues       []int  `json:"cues"`
+	Method     string `json:"method"`
+	// Model 是最终采用的译文所用的模型，ModelFailures 记录没有得到完整译文的每个模型及原因，
+	// 包括模型服务拒绝请求后不再使用、被跳过的模型
+	Model              string   `json:"model,omitempty"`
+	ModelFailures      []string `json:"model_failures,omitempty"`
+	Attempts           int      `json:"attempts"`
+	ValidationFailures []string `json:"validation_failures,omitempty"`
+	// valid 表示采用的译文通过了校验，只有这样的译文才写入缓存和断点日志
+	valid bool
+	// FallbackCues 是没有得到译文、以原文代替的字幕
+	FallbackCues []int `json:"fallback_cues,omitempty"`
+	// Error 是分组在所有模型上都失败时的原因，此时分组的所有字幕都以原文代替
+	Error string `json:"error,omitempty"`
+}
+
+// RunReport 是一次翻译运行的报告，保存在输出文件旁
+type RunReport struct {
+	Input        string         `json:"input"`
+	Outputs      []string       `json:"outputs"`
+	Model        string         `json:"model"`
+	SourceLang   string         `json:"source_lang"`
+	TargetLangs  []string       `json:"target_langs"`
+	StartedAt    time.Time      `json:"started_at"`
+	FinishedAt   time.Time      `json:"finished_at"`
+	Cues         int            `json:"cues"`
+	FallbackCues int            `json:"fallback_cues"`
+	Groups       []*GroupReport `json:"groups"`
+	Usage        *UsageSummary  `json:"usage,omitempty"`
+
+	mu sync.Mutex
+}
+
+// runReportPath 返回运行报告的保存路径，例如 movie.srt -> movie.report.json
+func runReportPath(input AgentInput) string {
+	return runSidecarPath(input, ".report.json")
+}
+
+// runSidecarPath 返回整次运行（而不是某个目标语言）的附属文件路径，suffix 替换输出文件的扩展名
+func runSidecarPath(input AgentInput, suffix string) string {
+	path := strings.ReplaceAll(input.OutputPath, langPlaceholder, strings.Join(input.TargetLangs, "+"))
+	return strings.TrimSuffix(path, filepath.Ext(path)) + suffix
+}
+
+func newGroupReport(group SubtitleGroup) *GroupReport {
+	cues := make([]int, len(group.Indices))
+	for i, idx := range group.Indices {
+		cues[i] = idx + 1
+	}
+	return &GroupReport{Group: group.ID, Cues: cues}
+}
+
+// AddGroup 添加分组记录，可以被多个 goroutine 并发调用
+func (r *RunReport) AddGroup(group *GroupReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Groups = append(r.Groups, group)
+}
+
+// Finish 按目标语言和分组序号排序分组记录并统计以原文代替译文的字幕数
+func (r *RunReport) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	slices.SortStableFunc(r.Groups, func(a, b *GroupReport) int {
+		if a.TargetLang != b.TargetLang {
+			return slices.Index(r.TargetLangs, a.TargetLang) - slices.Index(r.TargetLangs, b.TargetLang)
+		}
+		return a.Group - b.Group
+	})
+
+	r.FallbackCues = 0
+	for _, group := range r.Groups {
+		r.FallbackCues += len(group.FallbackCues)
+	}
+	r.FinishedAt = time.Now()
+}
+
+// FailedGroups 返回本次翻译中失败或没有得到完整译文的分组，这些分组没有写入断点日志，可以用 retry-failed 重新翻译
+func (r *RunReport) FailedGroups() []*GroupReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var failed []*GroupReport
+	for _, group := range r.Groups {
+		if group.Error != "" || len(group.FallbackCues) > 0 {
+			failed = append(failed, group)
+		}
+	}
+	return failed
+}
+
+// FallbackRatio 返回以原文代替译文的字幕占所有待翻译字幕的比例
+func (r *RunReport) FallbackRatio() float64 {
+	total := r.Cues * len(r.TargetLangs)
+	if total == 0 {
+		return 0
+	}
+	return float64(r.FallbackCues) / float64(total)
+}
+
+func SaveRunReport(path string, report *RunReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 模型提交译文的方式
+const (
+	// ResponseModeTool 通过 submit_translation 工具调用提交译文
+	ResponseModeTool = "tool"
+	// ResponseModeJSONSchema 通过模型服务的结构化输出（JSON Schema）直接输出 {"translations": [...]}
+	ResponseModeJSONSchema = "json-schema"
+	// ResponseModeText 在回复中直接输出 JSON，容忍代码块和常见的 JSON 格式错误
+	ResponseModeText = "text"
+)
+
+// responseModes 返回所有支持的响应模式
+func responseModes() []string {
+	return []string{ResponseModeTool, ResponseModeJSONSchema, ResponseModeText}
+}
+
+func validResponseMode(mode string) error {
+	switch mode {
+	case "", ResponseModeTool, ResponseModeJSONSchema, ResponseModeText:
+		return nil
+	default:
+		return fmt.Errorf("unknown response mode %q, supported: %s", mode, strings.Join(responseModes(), ", "))
+	}
+}
+
+// submitPrompt 按响应模式说明如何提交译文，Notice 放在任务说明之后，Check 是关键要求中的一条
+type submitPrompt struct {
+	Notice string
+	Check  string
+}
+
+var submitPrompts = map[string]submitPrompt{
+	ResponseModeTool: {
+		Notice: `重要提示：翻译完成后，您必须调用 "submit_translation" 函数提交您的翻译，而不是直接输出。
+		该函数会检查翻译后的数组长度是否与翻译前相同。如果验证失败，您必须更正翻译并重试。`,
+		Check: `始终使用 "submit_translation" 函数检查您的翻译`,
+	},
+	ResponseModeJSONSchema: {
+		Notice: `重要提示：翻译完成后，请直接输出 JSON 对象 {"translations": [...]} 提交您的翻译，不要输出其他内容。
+		提交的译文会被检查数组长度是否与翻译前相同。如果验证失败，您必须更正翻译并重新输出。`,
+		Check: `只输出 {"translations": [...]} 格式的 JSON 对象`,
+	},
+	ResponseModeText: {
+		Notice: `重要提示：翻译完成后，请直接输出 JSON 对象 {"translations": [...]} 提交您的翻译，不要输出解释或其他内容。
+		提交的译文会被检查数组长度是否与翻译前相同。如果验证失败，您必须更正翻译并重新输出。`,
+		Check: `只输出 {"translations": [...]} 格式的 JSON 对象`,
+	},
+}
+
+// translationSchema 返回 json-schema 模式下要求模型输出的 JSON Schema，与 submit_translation 工具的参数相同
+func translationSchema() (json.RawMessage, error) {
+	return toolParameters((&SubmitTranslationTool{}).Info())
+}
+
+// submission 是从模型回复中取出的译文提交，Arguments 与 submit_translation 工具的参数格式相同
+type submission struct {
+	Arguments string
+	Method    string
+	// ToolCall 为 nil 表示译文来自回复内容
+	ToolCall *schema.ToolCall
+}
+
+// extractSubmission 从模型回复中取出译文：有 submit_translation 工具调用时使用工具参数，
+// 否则从回复内容中解析 JSON，三种响应模式都经过同样的校验
+func extractSubmission(resp *schema.Message, mode string) (*submission, error) {
+	for i, toolCall := range resp.ToolCalls {
+		if toolCall.Function.Name == "submit_translation" {
+			return &submission{Arguments: toolCall.Function.Arguments, Method: GroupMethodToolCall, ToolCall: &resp.ToolCalls[i]}, nil
+		}
+	}
+
+	method := GroupMethodContent
+	if mode == ResponseModeJSONSchema {
+		method = GroupMethodJSONSchema
+	}
+	arguments, err := parseTranslationContent(resp.Content)
+	if err != nil {
+		return &submission{Method: method}, err
+	}
+	return &submission{Arguments: arguments, Method: method}, nil
+}
+
+// toolReplies 为回复中的每个工具调用生成工具结果消息，OpenAI 和 Anthropic 要求每个工具调用 ID 都有对应的结果。
+// 被采用的 submit_translation 调用返回 result，其余 submit_translation 调用说明已被忽略，未知工具返回错误
+func toolReplies(calls []schema.ToolCall, submitted *schema.ToolCall, result string) []*schema.Message {
+	var replies []*schema.Message
+	for _, call := range calls {
+		var content string
+		switch {
+		case submitted != nil && call.ID == submitted.ID:
+			content = result
+		case call.Function.Name == "submit_translation":
+			content = "ignored: only the first submit_translation call in a reply is checked"
+		default:
+			content = fmt.Sprintf("error: unknown tool %q, only submit_translation is available", call.Function.Name)
+		}
+		replies = append(replies, schema.ToolMessage(content, call.ID))
+	}
+	return replies
+}
+
+// parseJSONContent 把模型回复中的 JSON 解析到 v，会去掉 Markdown 代码块和前后的说明文字，
+// 并修复多余的逗号和字符串中未转义的换行。译文、术语提取和审校都使用它解析模型输出
+func parseJSONContent(content string, v any) error {
+	text := extractJSON(stripCodeFence(content))
+	if text == "" {
+		return errors.New("no JSON found in response")
+	}
+	if err := json.Unmarshal([]byte(text), v); err != nil {
+		if err := json.Unmarshal([]byte(repairJSON(text)), v); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseTranslationContent 从回复内容中解析译文，接受 JSON 数组或 {"translations": [...]} 对象，
+// 返回与 submit_translation 工具参数格式相同的 JSON
+func parseTranslationContent(content string) (string, error) {
+	var raw json.RawMessage
+	if err := parseJSONContent(content, &raw); err != nil {
+		return "", err
+	}
+
+	var req SubmitTranslationReq
+	if raw[0] == '[' {
+		if err := json.Unmarshal(raw, &req.Translations); err != nil {
+			return "", fmt.Errorf("invalid translation array: %w", err)
+		}
+	} else if err := json.Unmarshal(raw, &req); err != nil || req.Translations == nil {
+		return "", errors.New(`JSON object has no "translations" array`)
+	}
+	arguments, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	return string(arguments), nil
+}
+
+// stripCodeFence 去掉 ```json ... ``` 代码块标记，只保留第一个代码块的内容
+func stripCodeFence(content string) string {
+	start := strings.Index(content, "```")
+	if start < 0 {
+		return content
+	}
+	body := content[start+3:]
+	// 跳过代码块标记后的语言名称
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 {
+		body = body[newline+1:]
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return body
+}
+
+// extractJSON 取出第一个 [ 或 { 到最后一个对应的 ] 或 } 之间的内容，去掉前后的说明文字
+func extractJSON(text string) string {
+	start := strings.IndexAny(text, "[{")
+	if start < 0 {
+		return ""
+	}
+	closing := "]"
+	if text[start] == '{' {
+		closing = "}"
+	}
+	end := strings.LastIndex(text, closing)
+	if end < start {
+		return ""
+	}
+	return text[start : end+1]
+}
+
+// repairJSON 修复模型常见的 JSON 格式错误：字符串中未转义的换行和制表符，以及 ] 或 } 前多余的逗号
+func repairJSON(text string) string {
+	var b strings.Builder
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			case c == '\n':
+				b.WriteString(`\n`)
+				continue
+			case c == '\r':
+				b.WriteString(`\r`)
+				continue
+			case c == '\t':
+				b.WriteString(`\t`)
+				continue
+			}
+			b.WriteByte(c)
+			continue
+		}
+
+		if c == '"' {
+			inString = true
+		} else if c == ',' {
+			next := strings.TrimLeft(text[i+1:], " \t\r\n")
+			if next == "" || next[0] == ']' || next[0] == '}' {
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
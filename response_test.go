@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestStripCodeFence(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no fence", `{"translations": ["a"]}`, `{"translations": ["a"]}`},
+		{"json fence", "```json\n[\"a\"]\n```", "[\"a\"]\n"},
+		{"bare fence", "```\n[\"a\"]\n```", "[\"a\"]\n"},
+		{"prose around fence", "Here you go:\n```json\n[\"a\"]\n```\nHope it helps.", "[\"a\"]\n"},
+		{"only first block", "```json\n[\"a\"]\n```\n```json\n[\"b\"]\n```", "[\"a\"]\n"},
+		{"unterminated fence", "```json\n[\"a\"]", "[\"a\"]"},
+	}
+	for _, tt := range tests {
+		if got := stripCodeFence(tt.content); got != tt.want {
+			t.Errorf("%s: stripCodeFence(%q) = %q, want %q", tt.name, tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"array", `["a", "b"]`, `["a", "b"]`},
+		{"object", `{"translations": ["a"]}`, `{"translations": ["a"]}`},
+		{"prose before and after", `Sure! ["a", "b"] Let me know.`, `["a", "b"]`},
+		{"object wrapping array", `Result: {"translations": ["a"]} done`, `{"translations": ["a"]}`},
+		{"array of objects", `Terms: [{"source": "Neo"}] end`, `[{"source": "Neo"}]`},
+		{"no JSON", "I cannot translate this.", ""},
+		{"unclosed", `["a"`, ""},
+	}
+	for _, tt := range tests {
+		if got := extractJSON(tt.text); got != tt.want {
+			t.Errorf("%s: extractJSON(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"valid", `["a", "b"]`, `["a", "b"]`},
+		{"trailing comma in array", "[\"a\",\n\"b\",\n]", "[\"a\",\n\"b\"\n]"},
+		{"trailing comma in object", `{"translations": ["a"], }`, `{"translations": ["a"] }`},
+		{"raw newline in string", "[\"line one\nline two\"]", `["line one\nline two"]`},
+		{"raw tab and CR in string", "[\"a\tb\r\"]", `["a\tb\r"]`},
+		{"comma inside string kept", `["a,]", "b"]`, `["a,]", "b"]`},
+		{"escaped quote", `["say \"hi\",", "b",]`, `["say \"hi\",", "b"]`},
+	}
+	for _, tt := range tests {
+		got := repairJSON(tt.text)
+		if got != tt.want {
+			t.Errorf("%s: repairJSON(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+		if !json.Valid([]byte(got)) {
+			t.Errorf("%s: repairJSON(%q) = %q is not valid JSON", tt.name, tt.text, got)
+		}
+	}
+}
+
+func TestParseTranslationContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"object", `{"translations": ["你好", "再见"]}`, []string{"你好", "再见"}},
+		{"bare array", `["你好", "再见"]`, []string{"你好", "再见"}},
+		{"fenced", "```json\n{\"translations\": [\"你好\", \"再见\"]}\n```", []string{"你好", "再见"}},
+		{"prose wrapped", "Sure! Here is the translation:\n{\"translations\": [\"你好\", \"再见\"]}\nLet me know if you need changes.", []string{"你好", "再见"}},
+		{"trailing comma", "{\"translations\": [\n  \"你好\",\n  \"再见\",\n]}", []string{"你好", "再见"}},
+		{"raw newline", "{\"translations\": [\"你好\n朋友\", \"再见\"]}", []string{"你好\n朋友", "再见"}},
+		{"fenced with trailing comma and prose", "Here you go:\n```json\n[\n\"你好\",\n\"再见\",\n]\n```\nDone.", []string{"你好", "再见"}},
+		{"empty array", `{"translations": []}`, []string{}},
+	}
+	for _, tt := range tests {
+		arguments, err := parseTranslationContent(tt.content)
+		if err != nil {
+			t.Errorf("%s: parseTranslationContent: %v", tt.name, err)
+			continue
+		}
+		var req SubmitTranslationReq
+		if err := json.Unmarshal([]byte(arguments), &req); err != nil {
+			t.Errorf("%s: arguments %q are not submit_translation JSON: %v", tt.name, arguments, err)
+			continue
+		}
+		if !slices.Equal(req.Translations, tt.want) {
+			t.Errorf("%s: translations = %q, want %q", tt.name, req.Translations, tt.want)
+		}
+	}
+
+	for _, content := range []string{
+		"",
+		"I cannot translate this.",
+		`{"result": ["你好"]}`,
+		`{"translations": "你好"}`,
+		`["你好", 2]`,
+		`{"translations": ["你好"`,
+	} {
+		if arguments, err := parseTranslationContent(content); err == nil {
+			t.Errorf("parseTranslationContent(%q) = %q, want error", content, arguments)
+		}
+	}
+}
+
+func TestParseJSONContent(t *testing.T) {
+	content := "Here are the recurring terms:\n```json\n[\n  {\"source\": \"Neo\", \"target\": \"尼奥\"},\n  {\"source\": \"Matrix\", \"target\": \"矩阵\"},\n]\n```"
+	var terms []extractedTerm
+	if err := parseJSONContent(content, &terms); err != nil {
+		t.Fatalf("parseJSONContent: %v", err)
+	}
+	if len(terms) != 2 || terms[0].Source != "Neo" || terms[1].Target != "矩阵" {
+		t.Errorf("terms = %+v", terms)
+	}
+
+	var issues []ReviewIssue
+	if err := parseJSONContent("No problems found.", &issues); err == nil {
+		t.Error("parseJSONContent without JSON succeeded, want error")
+	}
+}
+
+func TestExtractSubmission(t *testing.T) {
+	toolCall := schema.ToolCall{ID: "call_1", Function: schema.FunctionCall{Name: "submit_translation", Arguments: `{"translations":["你好"]}`}}
+	tests := []struct {
+		name       string
+		resp       *schema.Message
+		mode       string
+		wantMethod string
+		wantCall   string
+	}{
+		{"tool call", schema.AssistantMessage("", []schema.ToolCall{toolCall}), ResponseModeTool, GroupMethodToolCall, "call_1"},
+		{"tool call after another tool", schema.AssistantMessage("", []schema.ToolCall{{ID: "call_0", Function: schema.FunctionCall{Name: "lookup"}}, toolCall}), ResponseModeTool, GroupMethodToolCall, "call_1"},
+		{"content in tool mode", schema.AssistantMessage(`["你好"]`, nil), ResponseModeTool, GroupMethodContent, ""},
+		{"json schema", schema.AssistantMessage(`{"translations":["你好"]}`, nil), ResponseModeJSONSchema, GroupMethodJSONSchema, ""},
+		{"text", schema.AssistantMessage("```json\n[\"你好\"]\n```", nil), ResponseModeText, GroupMethodContent, ""},
+	}
+	for _, tt := range tests {
+		sub, err := extractSubmission(tt.resp, tt.mode)
+		if err != nil {
+			t.Errorf("%s: extractSubmission: %v", tt.name, err)
+			continue
+		}
+		if sub.Method != tt.wantMethod {
+			t.Errorf("%s: method = %s, want %s", tt.name, sub.Method, tt.wantMethod)
+		}
+		if (sub.ToolCall == nil) != (tt.wantCall == "") || (sub.ToolCall != nil && sub.ToolCall.ID != tt.wantCall) {
+			t.Errorf("%s: tool call = %+v, want %q", tt.name, sub.ToolCall, tt.wantCall)
+		}
+		var req SubmitTranslationReq
+		if err := json.Unmarshal([]byte(sub.Arguments), &req); err != nil || !slices.Equal(req.Translations, []string{"你好"}) {
+			t.Errorf("%s: arguments = %q", tt.name, sub.Arguments)
+		}
+	}
+
+	sub, err := extractSubmission(schema.AssistantMessage("I cannot do that.", nil), ResponseModeText)
+	if err == nil || sub.Method != GroupMethodContent {
+		t.Errorf("unparseable content: method = %s, err = %v, want content method with error", sub.Method, err)
+	}
+}
+
+func TestToolReplies(t *testing.T) {
+	calls := []schema.ToolCall{
+		{ID: "call_0", Function: schema.FunctionCall{Name: "search_web"}},
+		{ID: "call_1", Function: schema.FunctionCall{Name: "submit_translation"}},
+		{ID: "call_2", Function: schema.FunctionCall{Name: "submit_translation"}},
+	}
+	replies := toolReplies(calls, &calls[1], "length mismatch")
+	if len(replies) != len(calls) {
+		t.Fatalf("got %d replies, want one per tool call", len(replies))
+	}
+	for i, reply := range replies {
+		if reply.Role != schema.Tool || reply.ToolCallID != calls[i].ID {
+			t.Errorf("reply %d = %s %s, want tool reply to %s", i, reply.Role, reply.ToolCallID, calls[i].ID)
+		}
+	}
+	if replies[1].Content != "length mismatch" {
+		t.Errorf("submitted call reply = %q, want the validation result", replies[1].Content)
+	}
+	if replies[0].Content == "" || replies[0].Content == replies[1].Content || replies[2].Content == replies[1].Content {
+		t.Errorf("other replies = %q, %q, want errors for the unknown and ignored calls", replies[0].Content, replies[2].Content)
+	}
+
+	// 回复内容中解析出的译文没有对应的工具调用，只回复未知工具
+	replies = toolReplies(calls[:1], nil, "length mismatch")
+	if len(replies) != 1 || replies[0].ToolCallID != "call_0" {
+		t.Errorf("replies without submitted call = %+v", replies)
+	}
+}
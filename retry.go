@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// 暂时性 API 错误的重试间隔：第 n 次重试等待 retryBaseDelay*2^(n-1)，不超过 retryMaxDelay，并加入随机抖动。
+// 服务端的 Retry-After 超过 retryMaxDelay 时（例如每日额度用尽）不再重试
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = time.Minute
+)
+
+// DefaultAPIRetries 是暂时性 API 错误的默认重试次数，与译文校验失败后的重试次数分开计算
+const DefaultAPIRetries = 5
+
+// responseInfo 记录一次模型请求最后收到的 HTTP 响应状态和 Retry-After，由 apiHTTPClient 的 Transport 填写。
+// 各模型服务的错误类型不同，有的也不保留响应头，因此通过 context 传递，统一判断是否为暂时性错误
+type responseInfo struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+type responseInfoKeyType struct{}
+
+var responseInfoKey = responseInfoKeyType{}
+
+// apiHTTPClient 是所有模型服务共用的 HTTP 客户端
+var apiHTTPClient = &http.Client{Transport: responseRecorder{next: http.DefaultTransport}}
+
+// responseRecorder 把响应状态和 Retry-After 记录到请求 context 中的 responseInfo
+type responseRecorder struct {
+	next http.RoundTripper
+}
+
+func (r responseRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if info, ok := req.Context().Value(responseInfoKey).(*responseInfo); ok && resp != nil {
+		info.StatusCode = resp.StatusCode
+		info.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return resp, err
+}
+
+// parseRetryAfter 解析以秒数或 HTTP 日期表示的 Retry-After，无法解析时返回 0
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// isTransientError 判断请求错误是否值得重试：429、5xx、连接被重置或超时等网络错误。
+// ctx 已取消，或服务端要求等待的时间超过 retryMaxDelay 时不重试，交给备用模型或由调用方稍后重新运行
+func isTransientError(ctx context.Context, err error, info *responseInfo) bool {
+	if err == nil || ctx.Err() != nil || info.RetryAfter > retryMaxDelay {
+		return false
+	}
+	if info.StatusCode == http.StatusTooManyRequests || info.StatusCode >= 500 {
+		return true
+	}
+	if info.StatusCode != 0 {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// FatalAPIError 表示模型服务拒绝了请求且原因与具体的字幕无关：API Key 无效（401）、余额不足（402）、
+// 没有权限（403）或模型不存在（404）。这类错误对所有分组都一样，翻译其他分组、重试或换用备用模型只会浪费请求，
+// 因此 TranslateGroups 遇到它时取消整个运行。400、413、422 等错误通常与请求内容有关（例如超出上下文长度、
+// 触发内容过滤），只影响当前分组，仍然按分组处理
+type FatalAPIError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *FatalAPIError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FatalAPIError) Unwrap() error {
+	return e.Err
+}
+
+// fatalAPIError 在 err 属于 FatalAPIError 描述的错误时返回包装后的错误，否则返回 nil。
+// 状态码优先取自 info，其次取自原生接口返回的 *APIError
+func fatalAPIError(err error, info *responseInfo) error {
+	status := info.StatusCode
+	var apiErr *APIError
+	if status == 0 && errors.As(err, &apiErr) {
+		status = apiErr.StatusCode
+	}
+	switch status {
+	case http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusForbidden, http.StatusNotFound:
+		return &FatalAPIError{StatusCode: status, Err: err}
+	}
+	return nil
+}
+
+// retryDelay 返回第 attempt 次重试（从 1 开始）前的等待时长。服务端给出 Retry-After 时按它等待，不超过 retryMaxDelay，
+// 否则使用带抖动的指数退避，在 [d/2, d) 之间随机取值，避免并发的分组同时重试
+func retryDelay(attempt int, info *responseInfo) time.Duration {
+	if info.RetryAfter > 0 {
+		return min(info.RetryAfter, retryMaxDelay)
+	}
+	d := min(retryBaseDelay<<min(attempt-1, 16), retryMaxDelay)
+	return d/2 + rand.N(d/2)
+}
+
+// sleepContext 等待 d 或直到 ctx 被取消
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFatalAPIError(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		info  responseInfo
+		fatal bool
+	}{
+		{"401 from transport", errors.New("unauthorized"), responseInfo{StatusCode: http.StatusUnauthorized}, true},
+		{"402 from transport", errors.New("insufficient quota"), responseInfo{StatusCode: http.StatusPaymentRequired}, true},
+		{"403 from APIError", &APIError{StatusCode: http.StatusForbidden}, responseInfo{}, true},
+		{"404 wrapped APIError", fmt.Errorf("request: %w", &APIError{StatusCode: http.StatusNotFound}), responseInfo{}, true},
+		{"400", &APIError{StatusCode: http.StatusBadRequest}, responseInfo{StatusCode: http.StatusBadRequest}, false},
+		{"422", errors.New("unprocessable"), responseInfo{StatusCode: http.StatusUnprocessableEntity}, false},
+		{"429", errors.New("rate limited"), responseInfo{StatusCode: http.StatusTooManyRequests}, false},
+		{"500", errors.New("server error"), responseInfo{StatusCode: http.StatusInternalServerError}, false},
+		{"network", errors.New("connection refused"), responseInfo{}, false},
+	}
+	for _, tt := range tests {
+		err := fatalAPIError(tt.err, &tt.info)
+		if (err != nil) != tt.fatal {
+			t.Errorf("%s: fatalAPIError = %v, want fatal %v", tt.name, err, tt.fatal)
+			continue
+		}
+		if err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: fatal error does not wrap the original error", tt.name)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempt  int
+		info     responseInfo
+		min, max time.Duration
+	}{
+		{"first backoff", 1, responseInfo{}, retryBaseDelay / 2, retryBaseDelay},
+		{"third backoff", 3, responseInfo{}, 2 * retryBaseDelay, 4 * retryBaseDelay},
+		{"backoff capped", 30, responseInfo{}, retryMaxDelay / 2, retryMaxDelay},
+		{"retry-after", 1, responseInfo{RetryAfter: 7 * time.Second}, 7 * time.Second, 7 * time.Second},
+		{"retry-after clamped", 1, responseInfo{RetryAfter: 24 * time.Hour}, retryMaxDelay, retryMaxDelay},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if d := retryDelay(tt.attempt, &tt.info); d < tt.min || d > tt.max {
+				t.Errorf("%s: retryDelay = %s, want between %s and %s", tt.name, d, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestIsTransientErrorRetryAfter(t *testing.T) {
+	err := errors.New("rate limited")
+	for _, tt := range []struct {
+		info responseInfo
+		want bool
+	}{
+		{responseInfo{StatusCode: http.StatusTooManyRequests}, true},
+		{responseInfo{StatusCode: http.StatusTooManyRequests, RetryAfter: retryMaxDelay}, true},
+		// 每日额度用尽时服务端会要求等待数小时，不在运行中等待
+		{responseInfo{StatusCode: http.StatusTooManyRequests, RetryAfter: 6 * time.Hour}, false},
+		{responseInfo{StatusCode: http.StatusServiceUnavailable, RetryAfter: 2 * retryMaxDelay}, false},
+	} {
+		if got := isTransientError(context.Background(), err, &tt.info); got != tt.want {
+			t.Errorf("isTransientError(%+v) = %v, want %v", tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		"-5":                            0,
+		"soon":                          0,
+		"Wed, 01 Jan 2025 12:02:00 GMT": 2 * time.Minute,
+		"Wed, 01 Jan 2025 11:00:00 GMT": 0,
+	} {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const (
+	reviewPrompt = `
+		您是一位资深的电影/电视剧字幕审校。我将提供一个 JSON 数组，每一项包含字幕编号 index、%s原文 source 和%s译文 translation，
+		数组是电影中连续的对话。请结合上下文逐条对照原文检查译文，找出以下问题：
+
+		1. mistranslation：误译，译文意思与原文不符
+		2. omission：漏译或多译了原文中的内容
+		3. tone：语气、称谓或风格与上下文不符
+		4. name：人名、地名或术语的译法前后不一致，或与术语表不符
+
+		只输出一个 JSON 数组，不要输出其他内容，没有问题时输出 []。每一项的格式为：
+		{"index": 字幕编号, "type": "问题类型", "problem": "问题说明", "suggestion": "修改后的完整译文"}
+		`
+	reviewContextPrompt = `
+		电影/电视剧上下文: %s
+		`
+	reviewGlossaryPrompt = `
+		术语表：以下术语必须使用指定的译法：
+		%s
+		`
+)
+
+// reviewWindowSize 是每次审校请求包含的字幕条数
+const reviewWindowSize = 30
+
+// ReviewIssue 是审校发现的一个问题，Index 为字幕在文件中的序号（从 1 开始）
+type ReviewIssue struct {
+	Index       int    `json:"index"`
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Translation string `json:"translation"`
+	Problem     string `json:"problem"`
+	Suggestion  string `json:"suggestion"`
+	// Applied 为 true 表示已自动采用建议的译文
+	Applied bool `json:"applied"`
+}
+
+// ReviewReport 是某个目标语言的审校报告
+type ReviewReport struct {
+	TargetLang string        `json:"target_lang"`
+	Model      string        `json:"model"`
+	Issues     []ReviewIssue `json:"issues"`
+}
+
+type reviewCue struct {
+	Index       int    `json:"index"`
+	Source      string `json:"source"`
+	Translation string `json:"translation"`
+}
+
+// reviewReportPath 返回审校报告的保存路径，例如 movie.srt -> movie.zh.review.json
+func reviewReportPath(outputPath string, lang string) string {
+	return sidecarPathForLang(outputPath, lang, ".review.json")
+}
+
+// NewReviewer 返回用于审校的翻译器。config.ReviewModel 为空时直接使用 translator，
+// 否则创建使用审校模型的翻译器，并共享 translator 的背景信息、术语表、限速器和用量统计
+func NewReviewer(ctx context.Context, config *TranslatorConfig, translator *Translator) (*Translator, error) {
+	if config.ReviewModel == "" || config.ReviewModel == translator.modelName {
+		return translator, nil
+	}
+
+	reviewConfig := *config
+	reviewConfig.Model = config.ReviewModel
+	reviewConfig.FallbackModels = nil
+	reviewer, err := NewTranslator(ctx, &reviewConfig)
+	if err != nil {
+		return nil, err
+	}
+	reviewer.context = translator.context
+	reviewer.glossaries = translator.glossaries
+	reviewer.limiter = translator.limiter
+	reviewer.usage = translator.usage
+	return reviewer, nil
+}
+
+// ReviewTranslations 按窗口把原文和译文一起交给模型审校，返回按字幕编号排序的问题列表。
+// fix 为 true 时把建议的译文写回字幕
+func (t *Translator) ReviewTranslations(ctx context.Context, subtitles []*SubtitleItem, sourceLang string, targetLang string, fix bool) (*ReviewReport, error) {
+	source := LookupLanguage(sourceLang)
+	target := LookupLanguage(targetLang)
+
+	// 译文以调用方传入的语言代码为键保存，因此这里使用 targetLang 而不是规范化后的 target.Code
+	cues := make([]reviewCue, len(subtitles))
+	for i, item := range subtitles {
+		cues[i] = reviewCue{Index: i + 1, Source: item.Text, Translation: item.Translation(targetLang)}
+	}
+	var windows [][]reviewCue
+	for start := 0; start < len(cues); start += reviewWindowSize {
+		windows = append(windows, cues[start:min(start+reviewWindowSize, len(cues))])
+	}
+	logReview.Info("开始审校译文", "reviewing translations", "model", t.modelName, "windows", len(windows), "cues", len(subtitles), "target_lang", target.Code)
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		issues   []ReviewIssue
+		sem      = make(chan struct{}, t.concurrency)
+	)
+	for i, window := range windows {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			found, err := t.reviewWindow(ctx, window, source, target)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			logReview.Info("窗口审校完成", "window reviewed", "window", i, "issues", len(found))
+			issues = append(issues, found...)
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Index < issues[j].Index })
+
+	if fix {
+		for i := range issues {
+			if issues[i].Suggestion == "" {
+				continue
+			}
+			subtitles[issues[i].Index-1].SetTranslation(targetLang, issues[i].Suggestion)
+			issues[i].Applied = true
+		}
+	}
+
+	return &ReviewReport{TargetLang: targetLang, Model: t.modelName, Issues: issues}, nil
+}
+
+// reviewWindow 审校一个窗口，只返回编号属于该窗口的问题
+func (t *Translator) reviewWindow(ctx context.Context, cues []reviewCue, source Language, target Language) ([]ReviewIssue, error) {
+	texts := make([]string, len(cues))
+	byIndex := make(map[int]reviewCue, len(cues))
+	for i, cue := range cues {
+		texts[i] = cue.Source
+		byIndex[cue.Index] = cue
+	}
+
+	jsonArray, err := json.Marshal(cues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	systemPrompt := fmt.Sprintf(reviewPrompt, source.Name, target.Name)
+	if t.context != "" {
+		systemPrompt += fmt.Sprintf(reviewContextPrompt, t.context)
+	}
+	glossaryText := formatGlossaryPrompt(t.matchGlossary(target, texts))
+	if glossaryText != "" {
+		systemPrompt += fmt.Sprintf(reviewGlossaryPrompt, glossaryText)
+	}
+
+	cacheKey := translationCacheKey(t.modelName, promptVersion, "review"+t.context+glossaryText, source.Code, target.Code, []string{string(jsonArray)})
+	content := ""
+	if cached, ok := t.cache.Get(cacheKey); ok && len(cached) == 1 {
+		content = cached[0]
+	} else {
+		resp, err := t.generate(ctx, StageReview, []*schema.Message{
+			schema.SystemMessage(systemPrompt),
+			schema.UserMessage(string(jsonArray)),
+		})
+		if err != nil {
+			logReview.Error("审校失败", "review failed", "err", err)
+			return nil, fmt.Errorf("failed to review translations: %w", err)
+		}
+		content = resp.Content
+	}
+
+	var found []ReviewIssue
+	if err := parseJSONContent(content, &found); err != nil {
+		// 审校不影响已有译文，模型输出无法解析时跳过这个窗口
+		logReview.Warn("解析模型输出失败，跳过这个窗口", "failed to parse model output, skipping window", "err", err, "content", sensitive(content))
+		return nil, nil
+	}
+
+	err = t.cache.Put(cacheKey, translationCacheEntry{
+		Model:        t.modelName,
+		SourceLang:   source.Code,
+		TargetLang:   target.Code,
+		Texts:        []string{string(jsonArray)},
+		Translations: []string{content},
+	})
+	if err != nil {
+		logReview.Warn("写入缓存失败", "failed to write cache", "err", err)
+	}
+
+	var issues []ReviewIssue
+	for _, issue := range found {
+		cue, ok := byIndex[issue.Index]
+		if !ok {
+			continue
+		}
+		issue.Source = cue.Source
+		issue.Translation = cue.Translation
+		issue.Suggestion = strings.TrimSpace(issue.Suggestion)
+		issue.Applied = false
+		if issue.Suggestion == cue.Translation {
+			issue.Suggestion = ""
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// SaveReviewReport 把审校报告写成 JSON 文件
+func SaveReviewReport(path string, report *ReviewReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal review report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write review report: %w", err)
+	}
+	return nil
+}
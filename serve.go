@@ -0,0 +1,697 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	jobStatusQueued    = "queued"
+	jobStatusRunning   = "running"
+	jobStatusSucceeded = "succeeded"
+	jobStatusFailed    = "failed"
+	jobStatusCanceled  = "canceled"
+)
+
+// maxUploadSize 是上传字幕和术语表的总大小上限
+const maxUploadSize = 32 << 20
+
+// reapInterval 是检查过期任务的间隔
+const reapInterval = time.Minute
+
+// ServeOptions 是 serve 命令的参数，Defaults 为任务未指定的选项提供默认值
+type ServeOptions struct {
+	Addr      string
+	WorkDir   string
+	MaxJobs   int
+	AuthToken string
+	Defaults  AgentInput
+	// JobTTL 是已结束任务的保留时长，超过后删除任务及其文件，0 表示不按时间删除
+	JobTTL time.Duration
+	// MaxFinishedJobs 是最多保留的已结束任务数，超过时删除最早结束的任务，0 表示不限制
+	MaxFinishedJobs int
+}
+
+// Job 是一个异步翻译任务，导出字段即 API 返回的任务状态
+type Job struct {
+	ID          string        `json:"id"`
+	Status      string        `json:"status"`
+	InputName   string        `json:"input_name"`
+	SourceLang  string        `json:"source_lang"`
+	TargetLangs []string      `json:"target_langs"`
+	Format      string        `json:"format"`
+	DoneGroups  int           `json:"done_groups"`
+	TotalGroups int           `json:"total_groups"`
+	Error       string        `json:"error,omitempty"`
+	Usage       *UsageSummary `json:"usage,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	StartedAt   *time.Time    `json:"started_at,omitempty"`
+	FinishedAt  *time.Time    `json:"finished_at,omitempty"`
+
+	dir      string
+	input    AgentInput
+	subtitle *Subtitle
+	cancel   context.CancelCauseFunc
+	// deleted 表示任务在运行时被 DELETE，任务结束后由 finishJob 删除任务目录
+	deleted bool
+}
+
+// JobServer 管理翻译任务并提供 REST API，任务只保存在内存中，服务重启后丢失
+type JobServer struct {
+	agent *SubtitleAgent
+	opts  ServeOptions
+	slots chan struct{}
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+var errJobCanceled = errors.New("job canceled")
+
+func NewJobServer(agent *SubtitleAgent, opts ServeOptions) (*JobServer, error) {
+	if err := os.MkdirAll(opts.WorkDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create work dir: %w", err)
+	}
+	return &JobServer{
+		agent: agent,
+		opts:  opts,
+		slots: make(chan struct{}, max(opts.MaxJobs, 1)),
+		jobs:  make(map[string]*Job),
+	}, nil
+}
+
+func (s *JobServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.handleCreate)
+	mux.HandleFunc("GET /jobs", s.handleList)
+	mux.HandleFunc("GET /jobs/{id}", s.handleGet)
+	mux.HandleFunc("GET /jobs/{id}/result", s.handleResult)
+	mux.HandleFunc("POST /jobs/{id}/cancel", s.handleCancel)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleDelete)
+	return s.authenticate(mux)
+}
+
+// authenticate 在设置了 AuthToken 时要求请求携带 Authorization: Bearer <token>
+func (s *JobServer) authenticate(next http.Handler) http.Handler {
+	if s.opts.AuthToken == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.AuthToken)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// snapshot 返回任务状态的副本，调用方需持有 s.mu
+func (j *Job) snapshot() Job {
+	return Job{
+		ID:          j.ID,
+		Status:      j.Status,
+		InputName:   j.InputName,
+		SourceLang:  j.SourceLang,
+		TargetLangs: j.TargetLangs,
+		Format:      j.Format,
+		DoneGroups:  j.DoneGroups,
+		TotalGroups: j.TotalGroups,
+		Error:       j.Error,
+		Usage:       j.Usage,
+		CreatedAt:   j.CreatedAt,
+		StartedAt:   j.StartedAt,
+		FinishedAt:  j.FinishedAt,
+	}
+}
+
+func (j *Job) finished() bool {
+	return j.Status == jobStatusSucceeded || j.Status == jobStatusFailed || j.Status == jobStatusCanceled
+}
+
+// saveUpload 把上传的文件保存到 dir 下，只保留文件名部分
+func saveUpload(r *http.Request, field string, dir string) (string, error) {
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	name := filepath.Base(header.Filename)
+	if name == "." || name == string(filepath.Separator) {
+		name = field
+	}
+	path := filepath.Join(dir, name)
+	out, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, file); err != nil {
+		out.Close()
+		return "", err
+	}
+	return path, out.Close()
+}
+
+// jobInput 根据表单字段和默认值生成任务的 AgentInput
+func (s *JobServer) jobInput(r *http.Request, inputPath string, dir string) (AgentInput, error) {
+	input := s.opts.Defaults
+	input.SubtitlePath = inputPath
+	input.Resume = false
+
+	if v := r.FormValue("source_lang"); v != "" {
+		input.SourceLang = v
+	}
+	if v := r.FormValue("target_lang"); v != "" {
+		input.TargetLangs = strings.Split(v, ",")
+	}
+	if v := r.FormValue("format"); v != "" {
+		input.OutputFormat = strings.ToLower(v)
+	}
+	switch input.OutputFormat {
+	case "srt", "ass", "vtt":
+	default:
+		return input, fmt.Errorf("unsupported format %q", input.OutputFormat)
+	}
+	if v := r.FormValue("ass_mode"); v != "" {
+		input.ASSMode = v
+	}
+	if preservesASS(input.ASSMode) && !isASSFile(inputPath) {
+		input.ASSMode = ASSModeRebuild
+	}
+
+	for name, field := range map[string]*bool{
+		"combine":       &input.CombineOutput,
+		"extract_terms": &input.ExtractTerms,
+		"review":        &input.Review,
+		"review_fix":    &input.ReviewFix,
+	} {
+		v := r.FormValue(name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return input, fmt.Errorf("invalid value %q for %s", v, name)
+		}
+		*field = b
+	}
+	input.Review = input.Review || input.ReviewFix
+
+	// 随请求上传的术语表与服务端通过 --glossary 指定的术语表一起使用
+	input.Glossaries = slices.Clone(input.Glossaries)
+	if len(r.MultipartForm.File["glossary"]) > 0 {
+		path, err := saveUpload(r, "glossary", filepath.Join(dir, "glossary"))
+		if err != nil {
+			return input, fmt.Errorf("failed to save glossary: %w", err)
+		}
+		input.Glossaries = append(input.Glossaries, path)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	input.OutputPath = filepath.Join(dir, "output", name+"."+input.OutputFormat)
+	return input, nil
+}
+
+func (s *JobServer) handleCreate(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid multipart form: %v", err))
+		return
+	}
+
+	id := newJobID()
+	dir := filepath.Join(s.opts.WorkDir, id)
+	for _, sub := range []string{"input", "glossary", "output"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	inputPath, err := saveUpload(r, "file", filepath.Join(dir, "input"))
+	if err != nil {
+		os.RemoveAll(dir)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("missing subtitle file: %v", err))
+		return
+	}
+	input, err := s.jobInput(r, inputPath, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	job := &Job{
+		ID:          id,
+		Status:      jobStatusQueued,
+		InputName:   filepath.Base(inputPath),
+		SourceLang:  input.SourceLang,
+		TargetLangs: input.TargetLangs,
+		Format:      input.OutputFormat,
+		CreatedAt:   time.Now(),
+		dir:         dir,
+		cancel:      cancel,
+	}
+	input.OnEvent = func(e Event) {
+		if e.Type != EventGroupDone {
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// 分组并发完成，事件到达的顺序不一定和计数一致
+		job.DoneGroups = max(job.DoneGroups, e.DoneGroups)
+		job.TotalGroups = e.TotalGroups
+	}
+	job.input = input
+
+	s.mu.Lock()
+	s.jobs[id] = job
+	snapshot := job.snapshot()
+	s.mu.Unlock()
+
+	logServe.Info("创建任务", "job created", "job", id, "input", job.InputName, "source_lang", input.SourceLang, "target_langs", strings.Join(input.TargetLangs, ","))
+	go s.runJob(ctx, job)
+
+	w.Header().Set("Location", "/jobs/"+id)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// runJob 等待空闲的执行槽位后运行任务
+func (s *JobServer) runJob(ctx context.Context, job *Job) {
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		s.finishJob(job, nil, context.Cause(ctx))
+		return
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	job.Status = jobStatusRunning
+	job.StartedAt = &now
+	s.mu.Unlock()
+
+	logServe.Info("开始任务", "job started", "job", job.ID)
+	output, err := s.agent.Run(ctx, job.input)
+	if err == nil && !output.Success {
+		err = errors.New(output.Message)
+	}
+	if ctx.Err() != nil {
+		err = context.Cause(ctx)
+	}
+	s.finishJob(job, &output, err)
+}
+
+func (s *JobServer) finishJob(job *Job, output *AgentOutput, err error) {
+	s.mu.Lock()
+	now := time.Now()
+	job.FinishedAt = &now
+	if output != nil && output.Report != nil {
+		job.Usage = output.Report.Usage
+	}
+	switch {
+	case errors.Is(err, errJobCanceled):
+		job.Status = jobStatusCanceled
+	case err != nil:
+		job.Status = jobStatusFailed
+		job.Error = err.Error()
+	default:
+		job.Status = jobStatusSucceeded
+		job.subtitle = output.Subtitle
+	}
+	logServe.Info("任务结束", "job finished", "job", job.ID, "status", job.Status)
+	deleted := job.deleted
+	s.mu.Unlock()
+
+	if deleted {
+		removeJobDir(job)
+	}
+	s.reap(now)
+}
+
+// removeJobDir 删除任务目录，失败时只记录日志
+func removeJobDir(job *Job) {
+	if err := os.RemoveAll(job.dir); err != nil {
+		logServe.Warn("删除任务文件失败", "failed to remove job files", "job", job.ID, "err", err)
+	}
+}
+
+// expireJobs 从 s.jobs 中移除结束时间超过 JobTTL 或超出 MaxFinishedJobs 的已结束任务并返回它们，调用方需持有 s.mu
+func (s *JobServer) expireJobs(now time.Time) []*Job {
+	var finished []*Job
+	for _, job := range s.jobs {
+		if job.finished() {
+			finished = append(finished, job)
+		}
+	}
+	slices.SortFunc(finished, func(a, b *Job) int { return a.FinishedAt.Compare(*b.FinishedAt) })
+
+	var expired []*Job
+	for i, job := range finished {
+		tooMany := s.opts.MaxFinishedJobs > 0 && len(finished)-i > s.opts.MaxFinishedJobs
+		tooOld := s.opts.JobTTL > 0 && now.Sub(*job.FinishedAt) > s.opts.JobTTL
+		if tooMany || tooOld {
+			delete(s.jobs, job.ID)
+			expired = append(expired, job)
+		}
+	}
+	return expired
+}
+
+// isJobID 判断目录名是否为 newJobID 生成的任务 ID
+func isJobID(name string) bool {
+	b, err := hex.DecodeString(name)
+	return err == nil && len(b) == 8
+}
+
+// reap 删除过期的任务及其文件。任务只保存在内存中，服务重启前留下的任务目录无法再访问，
+// 修改时间超过 JobTTL 时同样删除；工作目录中不是任务 ID 的文件和目录不受影响
+func (s *JobServer) reap(now time.Time) {
+	s.mu.Lock()
+	expired := s.expireJobs(now)
+	s.mu.Unlock()
+	for _, job := range expired {
+		logServe.Info("删除过期任务", "job expired", "job", job.ID)
+		removeJobDir(job)
+	}
+
+	if s.opts.JobTTL <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(s.opts.WorkDir)
+	if err != nil {
+		logServe.Warn("读取任务目录失败", "failed to read work dir", "dir", s.opts.WorkDir, "err", err)
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !isJobID(entry.Name()) {
+			continue
+		}
+		s.mu.Lock()
+		_, known := s.jobs[entry.Name()]
+		s.mu.Unlock()
+		info, err := entry.Info()
+		if known || err != nil || now.Sub(info.ModTime()) <= s.opts.JobTTL {
+			continue
+		}
+		logServe.Info("删除遗留的任务目录", "removing stale job dir", "job", entry.Name())
+		removeJobDir(&Job{ID: entry.Name(), dir: filepath.Join(s.opts.WorkDir, entry.Name())})
+	}
+}
+
+// reapLoop 每隔 reapInterval 删除一次过期的任务，直到 ctx 结束
+func (s *JobServer) reapLoop(ctx context.Context) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.reap(now)
+		}
+	}
+}
+
+func (s *JobServer) lookup(w http.ResponseWriter, r *http.Request) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+	}
+	return job, ok
+}
+
+func (s *JobServer) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job.snapshot())
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(jobs, func(a, b Job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *JobServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	snapshot := job.snapshot()
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+var resultContentTypes = map[string]string{
+	"srt": "application/x-subrip; charset=utf-8",
+	"ass": "text/x-ssa; charset=utf-8",
+	"vtt": "text/vtt; charset=utf-8",
+}
+
+// handleResult 按 format（默认为任务的输出格式）和 lang（默认为任务的全部目标语言，逗号分隔）生成字幕
+func (s *JobServer) handleResult(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	status, sub, input := job.Status, job.subtitle, job.input
+	s.mu.Unlock()
+	if status != jobStatusSucceeded {
+		writeError(w, http.StatusConflict, fmt.Sprintf("job is %s", status))
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = input.OutputFormat
+	}
+	contentType, ok := resultContentTypes[format]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported format %q", format))
+		return
+	}
+
+	langs := input.TargetLangs
+	if v := r.URL.Query().Get("lang"); v != "" {
+		langs = strings.Split(v, ",")
+		for _, lang := range langs {
+			if !slices.Contains(input.TargetLangs, lang) {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("job has no translation for %q", lang))
+				return
+			}
+		}
+	}
+
+	content, err := renderSubtitle(sub, input, format, langs)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	name := strings.TrimSuffix(job.InputName, filepath.Ext(job.InputName)) + "." + strings.Join(langs, "+") + "." + format
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	io.WriteString(w, content)
+}
+
+func (s *JobServer) handleCancel(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	finished := job.finished()
+	snapshot := job.snapshot()
+	s.mu.Unlock()
+	if finished {
+		writeError(w, http.StatusConflict, fmt.Sprintf("job is already %s", snapshot.Status))
+		return
+	}
+
+	logServe.Info("取消任务", "job canceled", "job", job.ID)
+	job.cancel(errJobCanceled)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// handleDelete 取消仍在运行的任务并删除任务及其文件。任务仍在运行时 agent 可能还在写入输出文件，
+// 任务目录在任务结束后由 finishJob 删除
+func (s *JobServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	job.cancel(errJobCanceled)
+
+	s.mu.Lock()
+	delete(s.jobs, job.ID)
+	finished := job.finished()
+	job.deleted = !finished
+	s.mu.Unlock()
+
+	if finished {
+		removeJobDir(job)
+	}
+	logServe.Info("删除任务", "job deleted", "job", job.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Serve 启动 HTTP 服务，ctx 结束时停止接受请求并取消所有任务
+func (s *JobServer) Serve(ctx context.Context) error {
+	server := &http.Server{Addr: s.opts.Addr, Handler: s.Handler()}
+
+	errCh := make(chan error, 1)
+	go func() {
+		logServe.Info("开始监听", "listening", "addr", s.opts.Addr, "work_dir", s.opts.WorkDir)
+		errCh <- server.ListenAndServe()
+	}()
+
+	s.reap(time.Now())
+	go s.reapLoop(ctx)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logServe.Info("正在停止服务", "shutting down")
+	s.mu.Lock()
+	for _, job := range s.jobs {
+		job.cancel(errJobCanceled)
+	}
+	s.mu.Unlock()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// DefaultJobDir 返回默认的任务目录
+func DefaultJobDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache dir: %w", err)
+	}
+	return filepath.Join(dir, "subai", "jobs"), nil
+}
+
+func newServeCmd() *cobra.Command {
+	var opts ServeOptions
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run an HTTP server exposing a translation job API",
+		Long: `Run an HTTP server that accepts subtitle uploads as asynchronous translation jobs.
+
+Endpoints:
+  POST   /jobs               multipart form: file (required), glossary, source_lang, target_lang,
+                             format, ass_mode, combine, extract_terms, review, review_fix
+  GET    /jobs               list jobs
+  GET    /jobs/{id}          job status and progress
+  GET    /jobs/{id}/result   download the translated subtitle (?format=srt|ass|vtt&lang=zh,ja)
+  POST   /jobs/{id}/cancel   cancel a queued or running job
+  DELETE /jobs/{id}          cancel and delete a job and its files
+
+Finished jobs and their files are deleted after --job-ttl, or earlier when more than
+--max-finished-jobs have finished. Translation flags set the defaults for jobs that do not specify them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireAPIKey(); err != nil {
+				return err
+			}
+			if opts.WorkDir == "" {
+				dir, err := DefaultJobDir()
+				if err != nil {
+					return err
+				}
+				opts.WorkDir = dir
+			}
+
+			opts.Defaults = AgentInput{
+				OutputFormat:     format,
+				SourceLang:       sourceLang,
+				TargetLangs:      targetLangs,
+				CombineOutput:    combine,
+				ASSStyle:         assStyle,
+				ASSMode:          assMode,
+				Glossaries:       glossaries,
+				ExtractTerms:     extractTerms,
+				Review:           review || reviewFix,
+				ReviewFix:        reviewFix,
+				MaxFallbackRatio: maxFallbackRatio,
+				Grouping:         grouping,
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			agent, err := NewSubtitleAgent(ctx, newTranslatorConfig())
+			if err != nil {
+				return fmt.Errorf("failed to create agent: %w", err)
+			}
+			server, err := NewJobServer(agent, opts)
+			if err != nil {
+				return err
+			}
+			if err := server.Serve(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	addTranslateFlags(cmd)
+	flags := cmd.Flags()
+	flags.StringVarP(&format, "format", "f", "srt", "Default output format for jobs (srt, ass or vtt)")
+	flags.StringVar(&opts.Addr, "addr", "127.0.0.1:8080", "Address to listen on")
+	flags.StringVar(&opts.WorkDir, "work-dir", "", "Directory for uploaded and translated files (default: <user cache dir>/subai/jobs)")
+	flags.IntVar(&opts.MaxJobs, "max-jobs", 2, "Maximum number of jobs translated at the same time")
+	flags.StringVar(&opts.AuthToken, "auth-token", "", "Require clients to send this bearer token (or SUBAI_AUTH_TOKEN)")
+	flags.DurationVar(&opts.JobTTL, "job-ttl", 24*time.Hour, "Delete finished jobs and their files this long after they finish (0 = keep until deleted)")
+	flags.IntVar(&opts.MaxFinishedJobs, "max-finished-jobs", 100, "Maximum number of finished jobs kept; the oldest are deleted first (0 = unlimited)")
+
+	return cmd
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// addTestJob 在 s 中加入一个任务并创建它的目录，finishedAt 为零值表示任务仍在运行
+func addTestJob(t *testing.T, s *JobServer, id string, finishedAt time.Time) *Job {
+	t.Helper()
+	dir := filepath.Join(s.opts.WorkDir, id)
+	if err := os.MkdirAll(filepath.Join(dir, "output"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_, cancel := context.WithCancelCause(context.Background())
+	job := &Job{ID: id, Status: jobStatusRunning, CreatedAt: finishedAt, dir: dir, cancel: cancel}
+	if !finishedAt.IsZero() {
+		job.Status = jobStatusSucceeded
+		job.FinishedAt = &finishedAt
+	}
+	s.jobs[id] = job
+	return job
+}
+
+func newTestJobServer(t *testing.T, ttl time.Duration, maxFinished int) *JobServer {
+	t.Helper()
+	s, err := NewJobServer(nil, ServeOptions{WorkDir: t.TempDir(), JobTTL: ttl, MaxFinishedJobs: maxFinished})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// remainingJobs 返回 s 中的任务 ID 和工作目录下的子目录
+func remainingJobs(t *testing.T, s *JobServer) (jobs []string, dirs []string) {
+	t.Helper()
+	for id := range s.jobs {
+		jobs = append(jobs, id)
+	}
+	entries, err := os.ReadDir(s.opts.WorkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		dirs = append(dirs, entry.Name())
+	}
+	slices.Sort(jobs)
+	return jobs, dirs
+}
+
+func TestJobServerReapTTL(t *testing.T) {
+	s := newTestJobServer(t, time.Hour, 0)
+	now := time.Now()
+	addTestJob(t, s, "00000000000000a1", now.Add(-2*time.Hour))
+	addTestJob(t, s, "00000000000000a2", now.Add(-30*time.Minute))
+	addTestJob(t, s, "00000000000000a3", time.Time{})
+
+	// 服务重启前留下的任务目录按修改时间删除，不是任务 ID 的目录不受影响
+	for _, name := range []string{"00000000000000b1", "00000000000000b2", "notajob"} {
+		if err := os.Mkdir(filepath.Join(s.opts.WorkDir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := now.Add(-3 * time.Hour)
+	for _, name := range []string{"00000000000000b1", "notajob"} {
+		if err := os.Chtimes(filepath.Join(s.opts.WorkDir, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.reap(now)
+	jobs, dirs := remainingJobs(t, s)
+	if want := []string{"00000000000000a2", "00000000000000a3"}; !slices.Equal(jobs, want) {
+		t.Errorf("jobs = %v, want %v", jobs, want)
+	}
+	if want := []string{"00000000000000a2", "00000000000000a3", "00000000000000b2", "notajob"}; !slices.Equal(dirs, want) {
+		t.Errorf("dirs = %v, want %v", dirs, want)
+	}
+}
+
+func TestJobServerReapMaxFinished(t *testing.T) {
+	s := newTestJobServer(t, 0, 2)
+	now := time.Now()
+	for i, id := range []string{"00000000000000a1", "00000000000000a2", "00000000000000a3", "00000000000000a4"} {
+		addTestJob(t, s, id, now.Add(-time.Duration(i)*time.Hour))
+	}
+	addTestJob(t, s, "00000000000000a5", time.Time{})
+
+	// 运行中的任务不计入，只保留最近结束的两个
+	s.reap(now)
+	jobs, dirs := remainingJobs(t, s)
+	if want := []string{"00000000000000a1", "00000000000000a2", "00000000000000a5"}; !slices.Equal(jobs, want) || !slices.Equal(dirs, want) {
+		t.Errorf("jobs = %v, dirs = %v, want %v", jobs, dirs, want)
+	}
+}
+
+func TestJobServerDelete(t *testing.T) {
+	s := newTestJobServer(t, 0, 0)
+	handler := s.Handler()
+	finished := addTestJob(t, s, "00000000000000a1", time.Now())
+	running := addTestJob(t, s, "00000000000000a2", time.Time{})
+
+	del := func(id string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/jobs/"+id, nil))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("DELETE %s = %d, want 204", id, rec.Code)
+		}
+	}
+
+	del(finished.ID)
+	if _, err := os.Stat(finished.dir); !os.IsNotExist(err) {
+		t.Errorf("finished job dir still exists after DELETE: %v", err)
+	}
+
+	// 运行中的任务可能还在写入文件，任务结束后才删除目录
+	del(running.ID)
+	if _, ok := s.jobs[running.ID]; ok {
+		t.Error("deleted job is still listed")
+	}
+	if _, err := os.Stat(running.dir); err != nil {
+		t.Errorf("running job dir removed before the job finished: %v", err)
+	}
+	s.finishJob(running, nil, errJobCanceled)
+	if _, err := os.Stat(running.dir); !os.IsNotExist(err) {
+		t.Errorf("deleted job dir still exists after the job finished: %v", err)
+	}
+}
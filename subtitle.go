@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -14,21 +16,51 @@ type SubtitleItem struct {
 	StartAt time.Duration
 	EndAt   time.Duration
 	Text    string
-	Chinese string
+	// Translations 按目标语言代码保存译文
+	Translations map[string]string
+
+	// source 是解析得到的原始条目，保留了 WebVTT 的位置、对齐、说话人等信息
+	source *astisub.Item
+	// assEvent 是 ParseASSSubtitle 解析时对应的对白在脚本中的序号
+	assEvent int
+}
+
+func (item *SubtitleItem) Translation(lang string) string {
+	return item.Translations[lang]
+}
+
+func (item *SubtitleItem) SetTranslation(lang string, text string) {
+	if item.Translations == nil {
+		item.Translations = make(map[string]string)
+	}
+	item.Translations[lang] = text
 }
 
 type Subtitle struct {
-	Items []*SubtitleItem
+	SourceLang string
+	Items      []*SubtitleItem
+
+	// source 是解析得到的原始字幕，保留了 WebVTT 的 STYLE、REGION 等信息
+	source *astisub.Subtitles
+	// assScript 是 ParseASSSubtitle 保留的原始 ASS 脚本
+	assScript *ASSScript
 }
 
 func ParseSubtitle(filePath string) (*Subtitle, error) {
-	s, err := astisub.OpenFile(filePath)
+	var s *astisub.Subtitles
+	var err error
+	if strings.EqualFold(filepath.Ext(filePath), ".vtt") {
+		s, err = parseWebVTT(filePath)
+	} else {
+		s, err = astisub.OpenFile(filePath)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse subtitle file: %w", err)
 	}
 
 	sub := &Subtitle{
-		Items: make([]*SubtitleItem, 0, len(s.Items)),
+		Items:  make([]*SubtitleItem, 0, len(s.Items)),
+		source: s,
 	}
 
 	for _, item := range s.Items {
@@ -41,6 +73,7 @@ func ParseSubtitle(filePath string) (*Subtitle, error) {
 			StartAt: item.StartAt,
 			EndAt:   item.EndAt,
 			Text:    strings.Join(lines, "\n"),
+			source:  item,
 		}
 		sub.Items = append(sub.Items, subItem)
 	}
@@ -48,7 +81,78 @@ func ParseSubtitle(filePath string) (*Subtitle, error) {
 	return sub, nil
 }
 
-func (s *Subtitle) GenerateSRT() string {
+// parseWebVTT 解析 WebVTT 字幕。astisub 只认识旧草案中写在文件头的 "Region: id=..." 行，
+// 因此先把规范中的 REGION 块转换成这种写法再交给它解析
+func parseWebVTT(filePath string) (*astisub.Subtitles, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return astisub.ReadFromWebVTT(strings.NewReader(webVTTRegionBlocksToHeaders(string(data))))
+}
+
+// webVTTRegionBlocksToHeaders 把第一条 cue 之前的 REGION 块（每行一个 id:fred 这样的设置）
+// 转换成 "Region: id=fred width=40%" 行，其余内容不变
+func webVTTRegionBlocksToHeaders(data string) string {
+	lines := strings.Split(data, "\n")
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.Contains(line, "-->") {
+			out = append(out, lines[i:]...)
+			break
+		}
+		if line != "REGION" {
+			out = append(out, lines[i])
+			continue
+		}
+
+		_, cr := strings.CutSuffix(lines[i], "\r")
+		var settings []string
+		for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			i++
+			for _, setting := range strings.Fields(lines[i]) {
+				settings = append(settings, strings.Replace(setting, ":", "=", 1))
+			}
+		}
+		region := "Region: " + strings.Join(settings, " ")
+		if cr {
+			region += "\r"
+		}
+		out = append(out, region)
+	}
+	return strings.Join(out, "\n")
+}
+
+// webVTTRegionHeadersToBlocks 把 astisub 输出的 "Region: id=fred width=40%" 行还原成规范中的 REGION 块
+func webVTTRegionHeadersToBlocks(data string) string {
+	lines := strings.Split(data, "\n")
+	out := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if strings.Contains(line, "-->") {
+			out = append(out, lines[i:]...)
+			break
+		}
+		settings, ok := strings.CutPrefix(line, "Region: ")
+		if !ok {
+			out = append(out, line)
+			continue
+		}
+
+		out = append(out, "REGION")
+		for _, setting := range strings.Fields(settings) {
+			out = append(out, strings.Replace(setting, "=", ":", 1))
+		}
+		// 每个 REGION 块之间用空行分隔
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "Region: ") {
+			out = append(out, "")
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// GenerateSRT 生成多语言字幕，译文按 targetLangs 的顺序排列在原文之上
+func (s *Subtitle) GenerateSRT(targetLangs ...string) string {
 	var builder strings.Builder
 	for _, item := range s.Items {
 		builder.WriteString(fmt.Sprintf("%d\n", item.Index))
@@ -57,9 +161,11 @@ func (s *Subtitle) GenerateSRT() string {
 		builder.WriteString(formatTime(item.EndAt))
 		builder.WriteString("\n")
 
-		if item.Chinese != "" {
-			builder.WriteString(item.Chinese)
-			builder.WriteString("\n")
+		for _, lang := range targetLangs {
+			if trans := item.Translation(lang); trans != "" {
+				builder.WriteString(trans)
+				builder.WriteString("\n")
+			}
 		}
 		builder.WriteString(item.Text)
 		builder.WriteString("\n\n")
@@ -67,7 +173,108 @@ func (s *Subtitle) GenerateSRT() string {
 	return builder.String()
 }
 
-func (s *Subtitle) GenerateASS() string {
+// GenerateVTT 生成多语言 WebVTT 字幕，译文排列在原文之上，
+// 并保留输入中的 STYLE、REGION、cue 设置（位置、对齐等）和说话人标签
+func (s *Subtitle) GenerateVTT(targetLangs ...string) (string, error) {
+	out := &astisub.Subtitles{}
+	if s.source != nil {
+		copied := *s.source
+		out = &copied
+	}
+	out.Items = make([]*astisub.Item, 0, len(s.Items))
+
+	for _, item := range s.Items {
+		cue := &astisub.Item{}
+		if item.source != nil {
+			copied := *item.source
+			cue = &copied
+		}
+		cue.StartAt = item.StartAt
+		cue.EndAt = item.EndAt
+
+		original := cue.Lines
+		if len(original) == 0 {
+			for _, text := range strings.Split(item.Text, "\n") {
+				original = append(original, astisub.Line{Items: []astisub.LineItem{{Text: text}}})
+			}
+		}
+
+		var lines []astisub.Line
+		for _, lang := range targetLangs {
+			trans := item.Translation(lang)
+			if trans == "" {
+				continue
+			}
+			transLines := strings.Split(trans, "\n")
+			for i, text := range transLines {
+				// 译文行数与原文一致时逐行沿用说话人，否则统一使用第一行的说话人
+				voice := original[0].VoiceName
+				if len(transLines) == len(original) {
+					voice = original[i].VoiceName
+				}
+				lines = append(lines, astisub.Line{VoiceName: voice, Items: []astisub.LineItem{{Text: text}}})
+			}
+		}
+		cue.Lines = append(lines, original...)
+		out.Items = append(out.Items, cue)
+	}
+
+	if len(out.Items) == 0 {
+		return "WEBVTT\n", nil
+	}
+
+	var buf bytes.Buffer
+	if err := out.WriteToWebVTT(&buf); err != nil {
+		return "", fmt.Errorf("failed to write WebVTT: %w", err)
+	}
+	return webVTTRegionHeadersToBlocks(buf.String()), nil
+}
+
+// ASSStyle 控制生成 ASS 字幕时译文和原文的字体样式，零值字段使用 DefaultASSStyle 中的值
+type ASSStyle struct {
+	FontName          string
+	TranslationSize   int
+	TranslationColour string
+	OriginalSize      int
+	OriginalColour    string
+}
+
+var DefaultASSStyle = ASSStyle{
+	FontName:          "Arial",
+	TranslationSize:   20,
+	TranslationColour: "&H00FFFFFF",
+	OriginalSize:      16,
+	OriginalColour:    "&H0080B2C2",
+}
+
+func (style ASSStyle) withDefaults() ASSStyle {
+	if style.FontName == "" {
+		style.FontName = DefaultASSStyle.FontName
+	}
+	if style.TranslationSize <= 0 {
+		style.TranslationSize = DefaultASSStyle.TranslationSize
+	}
+	if style.TranslationColour == "" {
+		style.TranslationColour = DefaultASSStyle.TranslationColour
+	}
+	if style.OriginalSize <= 0 {
+		style.OriginalSize = DefaultASSStyle.OriginalSize
+	}
+	if style.OriginalColour == "" {
+		style.OriginalColour = DefaultASSStyle.OriginalColour
+	}
+	return style
+}
+
+// GenerateASS 生成多语言 ASS 字幕，每种目标语言使用各自的样式，原文位于最下方
+func (s *Subtitle) GenerateASS(style ASSStyle, targetLangs ...string) string {
+	style = style.withDefaults()
+	source := LookupLanguage(s.SourceLang)
+	targets := make([]Language, len(targetLangs))
+	for i, lang := range targetLangs {
+		targets[i] = LookupLanguage(lang)
+	}
+
 	var builder strings.Builder
 	builder.WriteString("[Script Info]\n")
 	builder.WriteString("ScriptType: v4.00+\n")
@@ -76,28 +283,34 @@ func (s *Subtitle) GenerateASS() string {
 
 	builder.WriteString("[V4+ Styles]\n")
 	builder.WriteString("Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n")
-	builder.WriteString("Style: Chinese,Arial,20,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,2,10,10,10,1\n")
-	builder.WriteString("Style: English,Arial,16,&H0080B2C2,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,2,10,10,10,1\n\n")
+	for _, target := range targets {
+		builder.WriteString(fmt.Sprintf("Style: %s,%s,%d,%s,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,2,10,10,10,1\n",
+			target.StyleName, style.FontName, style.TranslationSize, style.TranslationColour))
+	}
+	builder.WriteString(fmt.Sprintf("Style: %s,%s,%d,%s,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,0,2,10,10,10,1\n\n",
+		source.StyleName, style.FontName, style.OriginalSize, style.OriginalColour))
 
 	builder.WriteString("[Events]\n")
 	builder.WriteString("Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
 
 	for _, item := range s.Items {
-		if item.Chinese != "" {
-			chinese := fmt.Sprintf("{\\rChinese}%s", escapeASSText(item.Chinese))
-			english := fmt.Sprintf("{\\rEnglish}%s", escapeASSText(item.Text))
-			text := chinese + "\\N" + english
-			builder.WriteString(fmt.Sprintf("Dialogue: 0,%s,%s,Chinese,,0,0,0,,%s\n",
-				formatASSTime(item.StartAt),
-				formatASSTime(item.EndAt),
-				text))
-		} else {
-			text := fmt.Sprintf("{\\rEnglish}%s", escapeASSText(item.Text))
-			builder.WriteString(fmt.Sprintf("Dialogue: 0,%s,%s,English,,0,0,0,,%s\n",
-				formatASSTime(item.StartAt),
-				formatASSTime(item.EndAt),
-				text))
+		var parts []string
+		styleName := source.StyleName
+		for i, target := range targets {
+			if trans := item.Translation(targetLangs[i]); trans != "" {
+				if len(parts) == 0 {
+					styleName = target.StyleName
+				}
+				parts = append(parts, fmt.Sprintf("{\\r%s}%s", target.StyleName, escapeASSText(trans)))
+			}
 		}
+		parts = append(parts, fmt.Sprintf("{\\r%s}%s", source.StyleName, escapeASSText(item.Text)))
+
+		builder.WriteString(fmt.Sprintf("Dialogue: 0,%s,%s,%s,,0,0,0,,%s\n",
+			formatASSTime(item.StartAt),
+			formatASSTime(item.EndAt),
+			styleName,
+			strings.Join(parts, "\\N")))
 	}
 
 	return builder.String()
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testVTT = `WEBVTT
+
+STYLE
+::cue(.loud) { color: red }
+
+REGION
+id:fred
+width:40%
+lines:3
+regionanchor:0%,100%
+viewportanchor:10%,90%
+scroll:up
+
+REGION
+id:bill width:40% lines:3
+regionanchor:100%,100% viewportanchor:90%,90%
+
+1
+00:00:01.000 --> 00:00:02.000 region:fred align:left
+<v Fred>Hi, my name is Fred
+
+2
+00:00:02.500 --> 00:00:04.000 region:bill align:right
+<v Bill>Hi, I'm Bill
+`
+
+func TestParseSubtitleWebVTTRegions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "regions.vtt")
+	if err := os.WriteFile(path, []byte(testVTT), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := ParseSubtitle(path)
+	if err != nil {
+		t.Fatalf("ParseSubtitle: %v", err)
+	}
+	if len(sub.Items) != 2 || sub.Items[0].Text != "Hi, my name is Fred" {
+		t.Fatalf("items = %+v", sub.Items)
+	}
+	sub.Items[0].SetTranslation("zh", "嗨，我叫弗雷德")
+	sub.Items[1].SetTranslation("zh", "嗨，我是比尔")
+
+	out, err := sub.GenerateVTT("zh")
+	if err != nil {
+		t.Fatalf("GenerateVTT: %v", err)
+	}
+	// 输出使用规范中的 REGION 块，不再出现旧草案的 Region: 行
+	for _, want := range []string{
+		"REGION\nid:bill\nlines:3\nregionanchor:100%,100%\nviewportanchor:90%,90%\nwidth:40%\n\nREGION\nid:fred\nlines:3\nregionanchor:0%,100%\nscroll:up\nviewportanchor:10%,90%\nwidth:40%\n\n",
+		"::cue(.loud) { color: red }",
+		"00:00:01.000 --> 00:00:02.000 align:left region:fred\n<v Fred>嗨，我叫弗雷德\n<v Fred>Hi, my name is Fred\n",
+		"00:00:02.500 --> 00:00:04.000 align:right region:bill\n<v Bill>嗨，我是比尔\n<v Bill>Hi, I'm Bill\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Region:") {
+		t.Errorf("output contains a legacy Region: header:\n%s", out)
+	}
+
+	// 输出的 REGION 块可以被再次解析
+	again := filepath.Join(t.TempDir(), "again.vtt")
+	if err := os.WriteFile(again, []byte(out), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseSubtitle(again); err != nil {
+		t.Errorf("ParseSubtitle(output): %v", err)
+	}
+}
+
+func TestWebVTTRegionBlocksToHeaders(t *testing.T) {
+	in := "WEBVTT\r\n\r\nREGION\r\nid:fred\r\nwidth:40%\r\n\r\n00:00:01.000 --> 00:00:02.000\r\nREGION\r\n"
+	want := "WEBVTT\r\n\r\nRegion: id=fred width=40%\r\n\r\n00:00:01.000 --> 00:00:02.000\r\nREGION\r\n"
+	if got := webVTTRegionBlocksToHeaders(in); got != want {
+		t.Errorf("webVTTRegionBlocksToHeaders = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+const termExtractionPrompt = `
+		您是一位专业的电影/电视剧字幕翻译。我将提供一部电影/剧集的%s字幕全文（或其中连续的一段）。
+		请找出其中反复出现的专有名词、人物名字、地名、组织名、口头禅和领域术语，并为每个术语确定一个统一的%s译法，
+		使整部作品的翻译前后一致。
+
+		要求：
+
+		1. 只输出一个 JSON 数组，不要输出其他内容，每一项的格式为 {"source": "原文术语", "target": "译法", "note": "简短说明"}
+		2. source 必须与字幕中出现的原文完全一致
+		3. 不要包含普通词汇，最多输出 %d 个术语
+		`
+
+// maxTermsPerChunk 是每段字幕最多提取的术语数
+const maxTermsPerChunk = 50
+
+type extractedTerm struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Note   string `json:"note"`
+}
+
+// termsGlossaryPath 返回自动提取的术语表的保存路径，例如 movie.srt -> movie.zh.glossary.csv
+func termsGlossaryPath(outputPath string, lang string) string {
+	return sidecarPathForLang(outputPath, lang, ".glossary.csv")
+}
+
+// ExtractTerms 扫描整部字幕，提取反复出现的专有名词、人名、口头禅和领域术语并确定统一译法。
+// 已在术语表中的术语不会重复提取，只出现一次的术语会被丢弃
+func (t *Translator) ExtractTerms(ctx context.Context, subtitles []*SubtitleItem, sourceLang string, targetLang string) (*Glossary, error) {
+	source := LookupLanguage(sourceLang)
+	target := LookupLanguage(targetLang)
+	logTerms.Info("开始提取术语", "extracting terms", "source_lang", source.Code, "target_lang", target.Code)
+
+	chunks := termChunks(subtitles)
+
+	texts := make([]string, len(subtitles))
+	for i, item := range subtitles {
+		texts[i] = item.Text
+	}
+
+	glossary := &Glossary{}
+	for i, chunk := range chunks {
+		logTerms.Info("分析字幕片段", "analysing chunk", "chunk", i+1, "chunks", len(chunks))
+		terms, err := t.extractChunkTerms(ctx, chunk, source, target)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, term := range terms {
+			term.Source = strings.TrimSpace(term.Source)
+			term.Target = strings.TrimSpace(term.Target)
+			if term.Source == "" || term.Target == "" {
+				continue
+			}
+			if _, ok := glossary.Lookup(term.Source); ok {
+				continue
+			}
+			if _, ok := t.lookupGlossary(target, term.Source); ok {
+				continue
+			}
+
+			entry := NewGlossaryEntry(term.Source, term.Target, strings.TrimSpace(term.Note))
+			if countOccurrences(entry, texts) < 2 {
+				continue
+			}
+			glossary.Entries = append(glossary.Entries, entry)
+		}
+	}
+
+	logTerms.Info("术语提取完成", "terms extracted", "terms", len(glossary.Entries))
+	return glossary, nil
+}
+
+// termChunks 把字幕全文分段，字幕较长时分段提取，每段与背景信息总结的样本大小相同
+func termChunks(subtitles []*SubtitleItem) []string {
+	var chunks []string
+	chunk := ""
+	maxChars := 20000
+	for _, item := range subtitles {
+		if chunk != "" && len(chunk)+len(item.Text) > maxChars {
+			chunks = append(chunks, chunk)
+			chunk = ""
+		}
+		if chunk != "" {
+			chunk += "\n"
+		}
+		chunk += item.Text
+	}
+	if chunk != "" {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func (t *Translator) extractChunkTerms(ctx context.Context, chunk string, source Language, target Language) ([]extractedTerm, error) {
+	cacheKey := translationCacheKey(t.modelName, promptVersion, "terms", source.Code, target.Code, []string{chunk})
+	content := ""
+	if cached, ok := t.cache.Get(cacheKey); ok && len(cached) == 1 {
+		logTerms.Debug("命中缓存", "cache hit")
+		content = cached[0]
+	} else {
+		messages := []*schema.Message{
+			schema.SystemMessage(fmt.Sprintf(termExtractionPrompt, source.Name, target.Name, maxTermsPerChunk)),
+			schema.UserMessage(chunk),
+		}
+		resp, err := t.generate(ctx, StageTerms, messages)
+		if err != nil {
+			logTerms.Error("提取失败", "term extraction failed", "err", err)
+			return nil, fmt.Errorf("failed to extract terms: %w", err)
+		}
+		content = resp.Content
+	}
+
+	var terms []extractedTerm
+	if err := parseJSONContent(content, &terms); err != nil {
+		// 术语提取只是辅助步骤，模型输出无法解析时跳过这一段
+		logTerms.Warn("解析模型输出失败，跳过这一段", "failed to parse model output, skipping chunk", "err", err, "content", sensitive(content))
+		return nil, nil
+	}
+
+	err := t.cache.Put(cacheKey, translationCacheEntry{
+		Model:        t.modelName,
+		SourceLang:   source.Code,
+		TargetLang:   target.Code,
+		Texts:        []string{chunk},
+		Translations: []string{content},
+	})
+	if err != nil {
+		logTerms.Warn("写入缓存失败", "failed to write cache", "err", err)
+	}
+	return terms, nil
+}
+
+// countOccurrences 统计术语在所有字幕中出现的次数
+func countOccurrences(entry *GlossaryEntry, texts []string) int {
+	count := 0
+	for _, text := range texts {
+		count += len(entry.pattern.FindAllStringIndex(text, -1))
+	}
+	return count
+}
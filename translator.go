@@ -3,15 +3,23 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
-	"github.com/cloudwego/eino-ext/components/model/openai"
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 )
 
+// promptVersion 标识翻译提示词的版本，修改提示词时需要递增以使翻译缓存失效
+const promptVersion = "4"
+
 const (
 	summarizePrompt = `
         您是一位电影/剧集专家。
@@ -19,51 +27,153 @@ const (
 		以及有助于准确翻译的上下文信息。请尽量简洁（2-3句话）。
         `
 	translatePrompt = `
-		您是一位专业的电影/电视剧字幕翻译。我将提供一个长度为 %d 的英文字幕 JSON 数组。
-		您的任务是根据上下文将数组中每一项翻译成中文。数组是电影中时间相近的对话，翻译时请考虑上下文。
+		您是一位专业的电影/电视剧字幕翻译。我将提供一个长度为 %d 的%s字幕 JSON 数组。
+		您的任务是根据上下文将数组中每一项翻译成%s。数组是电影中时间相近的对话，翻译时请考虑上下文。
 		
-		重要提示：翻译完成后，您必须调用 "submit_translation" 函数提交您的翻译，而不是直接输出。
-		该函数会检查翻译后的数组长度是否与翻译前相同。如果验证失败，您必须更正翻译并重试。
+		%s
 		
 		关键要求：
 		
 		1. 每个输入字幕必须恰好对应一条输出翻译
 		2. 请勿添加或删除任何数组元素
-		3. 始终使用 "submit_translation" 函数检查您的翻译
+		3. %s
 		4. 如果验证失败，请更正翻译并重试
 
 		预期数组长度：%d
 		`
 	translateWithContextPrompt = `
-		您是一位专业的电影/电视剧字幕翻译。我将提供一个长度为 %d 的英文字幕 JSON 数组。
-		您的任务是根据上下文将数组中每一项翻译成中文。数组是电影中时间相近的对话，翻译时请考虑上下文。
+		您是一位专业的电影/电视剧字幕翻译。我将提供一个长度为 %d 的%s字幕 JSON 数组。
+		您的任务是根据上下文将数组中每一项翻译成%s。数组是电影中时间相近的对话，翻译时请考虑上下文。
 		
-		重要提示：翻译完成后，您必须调用 "submit_translation" 函数提交您的翻译，而不是直接输出。
-		该函数会检查翻译后的数组长度是否与翻译前相同。如果验证失败，您必须更正翻译并重试。
+		%s
 		
 		关键要求：
 		
 		1. 每个输入字幕必须恰好对应一条输出翻译
 		2. 请勿添加或删除任何数组元素
-		3. 始终使用 "submit_translation" 函数检查您的翻译
+		3. %s
 		4. 如果验证失败，请更正翻译并重试
 
 		预期数组长度：%d
 		
 		电影/电视剧上下文: %s
 		`
+	glossaryPrompt = `
+		术语表：以下术语出现在本组字幕中，必须严格使用指定的译法，提交的译文会被检查是否使用了这些译法：
+		%s
+		`
+	neighbourContextPrompt = `
+		前后文：以下是本组字幕前后相邻的对话，仅供理解代词、称呼和前后呼应的内容，不需要翻译，也不要包含在提交的译文中。
+		前文中 "=>" 之后是上一组已采用的译文，请保持称呼和用词一致；上一组的译文尚未确定时只提供原文：
+		%s
+		`
 )
 
+// 提供给模型的前后文条数
+const (
+	prevContextCues = 10
+	nextContextCues = 3
+)
+
+type TranslatorConfig struct {
+	// Provider 模型服务名称，为空时使用 OpenAI 兼容接口
+	Provider string
+	APIKey   string
+	BaseURL  string
+	Model    string
+
+	// Concurrency 同时翻译的分组数，小于等于 1 时顺序翻译
+	Concurrency int
+	// RequestsPerMinute 每分钟最多请求数，0 表示不限制
+	RequestsPerMinute int
+	// TokensPerMinute 每分钟最多（估算）token 数，0 表示不限制
+	TokensPerMinute int
+	// APIRetries 暂时性 API 错误的最多重试次数，0 表示不重试
+	APIRetries int
+
+	// ReviewModel 审校译文使用的模型，为空时使用 Model
+	ReviewModel string
+	// ResponseMode 模型提交译文的方式：ResponseModeTool（默认）、ResponseModeJSONSchema 或 ResponseModeText
+	ResponseMode string
+	// FallbackModels 按顺序排列的备用模型，格式见 ModelSpec。主模型翻译某个分组出错或重试用尽时依次换用
+	FallbackModels []string
+
+	// Cache 翻译缓存，nil 表示不使用缓存
+	Cache *TranslationCache
+	// Prices 补充或覆盖内置价格表，用于计算费用
+	Prices map[string]ModelPrice
+}
+
 type Translator struct {
-	model   model.ToolCallingChatModel
-	context string
+	model model.ToolCallingChatModel
+	// translateModel 用于翻译分组，按响应模式绑定了 submit_translation 工具或配置了 JSON Schema；
+	// 背景总结、术语提取和审校使用 model
+	translateModel model.ToolCallingChatModel
+	responseMode   string
+	modelName      string
+	fallbacks      []fallbackModel
+	// rejected 由所有副本共享，记录模型服务拒绝请求的模型
+	rejected    *rejectedModels
+	apiRetries  int
+	context     string
+	concurrency int
+	limiter     *RateLimiter
+	cache       *TranslationCache
+	// glossaries 按目标语言代码保存术语表，空字符串键适用于所有目标语言
+	glossaries map[string]*Glossary
+	usage      *UsageTracker
+	// events 接收分组开始和重试事件，可以为 nil
+	events EventFunc
+}
+
+// fallbackModel 是翻译分组失败时换用的模型
+type fallbackModel struct {
+	model          model.ToolCallingChatModel
+	translateModel model.ToolCallingChatModel
+	name           string
+}
+
+// rejectedModels 记录本次运行中模型服务拒绝请求（*FatalAPIError）的模型，键是模型在 modelChain 中的位置。
+// 这些模型不再使用，nil 表示不记录
+type rejectedModels struct {
+	mu     sync.Mutex
+	errors map[int]error
+}
+
+func newRejectedModels() *rejectedModels {
+	return &rejectedModels{errors: make(map[int]error)}
+}
+
+// get 返回模型被拒绝时的错误，没有被拒绝时返回 nil
+func (r *rejectedModels) get(i int) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.errors[i]
+}
+
+// mark 记录模型被拒绝
+func (r *rejectedModels) mark(i int, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors[i] = err
 }
 
 type SubmitTranslationUserdata struct {
 	ExpectedCount int
+	// Sources 和 Glossary 用于检查译文是否使用了术语表中的译法
+	Sources  []string
+	Glossary []*GlossaryEntry
 }
 
-var submitTranslationUserdataKey = SubmitTranslationUserdata{}
+type submitTranslationUserdataKeyType struct{}
+
+var submitTranslationUserdataKey = submitTranslationUserdataKeyType{}
 
 type SubmitTranslationReq struct {
 	Translations []string `json:"translations"`
@@ -94,15 +204,15 @@ func (t *SubmitTranslationTool) Info() *schema.ToolInfo {
 }
 
 func (t *SubmitTranslationTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
-	log.Printf("[分组翻译] 提交翻译结果")
-	log.Printf("[分组翻译] 输入: %s", argumentsInJSON)
+	logTranslate.Debug("提交翻译结果", "translation submitted", "arguments", sensitive(argumentsInJSON))
 	var input SubmitTranslationReq
 	if err := json.Unmarshal([]byte(argumentsInJSON), &input); err != nil {
-		log.Printf("[分组翻译] 输入解析失败: %v", err)
+		logTranslate.Warn("输入解析失败", "failed to parse submitted arguments", "err", err)
 		return "", fmt.Errorf("invalid input: %w", err)
 	}
 
-	expectedCount := ctx.Value(submitTranslationUserdataKey).(*SubmitTranslationUserdata).ExpectedCount
+	userdata := ctx.Value(submitTranslationUserdataKey).(*SubmitTranslationUserdata)
+	expectedCount := userdata.ExpectedCount
 
 	if len(input.Translations) != expectedCount {
 		output := SubmitTranslationResp{
@@ -110,7 +220,17 @@ func (t *SubmitTranslationTool) InvokableRun(ctx context.Context, argumentsInJSO
 			Reason: fmt.Sprintf("翻译后数组长度 %d 与翻译前数组长度 %d 不匹配！请重新翻译，确保数组中每一个输入都对应一个输出。", len(input.Translations), expectedCount),
 		}
 		result, _ := json.Marshal(output)
-		log.Printf("[分组翻译] 输出: %s", string(result))
+		logTranslate.Debug("校验结果", "validation result", "result", string(result))
+		return string(result), nil
+	}
+
+	if violations := glossaryViolations(userdata.Glossary, userdata.Sources, input.Translations); len(violations) > 0 {
+		output := SubmitTranslationResp{
+			Valid:  false,
+			Reason: "译文没有使用术语表中的译法：" + strings.Join(violations, "；") + "。请修改这些译文并重新提交。",
+		}
+		result, _ := json.Marshal(output)
+		logTranslate.Debug("校验结果", "validation result", "result", sensitive(string(result)))
 		return string(result), nil
 	}
 
@@ -119,18 +239,78 @@ func (t *SubmitTranslationTool) InvokableRun(ctx context.Context, argumentsInJSO
 		Reason: "正确",
 	}
 	result, _ := json.Marshal(output)
-	log.Printf("[分组翻译] 输出: %s", string(result))
+	logTranslate.Debug("校验结果", "validation result", "result", string(result))
 	return string(result), nil
 }
 
-func NewTranslator(ctx context.Context, apiKey string, baseURL string, modelName string) (*Translator, error) {
-	chatModel, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
-		APIKey:  apiKey,
-		Model:   modelName,
-		BaseURL: baseURL,
-	})
+func NewTranslator(ctx context.Context, config *TranslatorConfig) (*Translator, error) {
+	if err := validResponseMode(config.ResponseMode); err != nil {
+		return nil, err
+	}
+	chatModel, translateModel, err := newTranslationModels(ctx, config.Provider, ProviderConfig{
+		APIKey:  config.APIKey,
+		BaseURL: config.BaseURL,
+		Model:   config.Model,
+	}, config.ResponseMode)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create chat model: %w", err)
+		return nil, err
+	}
+
+	var fallbacks []fallbackModel
+	for _, s := range config.FallbackModels {
+		spec, err := ParseModelSpec(s)
+		if err != nil {
+			return nil, err
+		}
+		providerName, providerConfig, err := config.fallbackProviderConfig(spec)
+		if err != nil {
+			return nil, err
+		}
+		fallback, fallbackTranslate, err := newTranslationModels(ctx, providerName, providerConfig, config.ResponseMode)
+		if err != nil {
+			return nil, fmt.Errorf("fallback model %s: %w", spec, err)
+		}
+		fallbacks = append(fallbacks, fallbackModel{model: fallback, translateModel: fallbackTranslate, name: spec.Model})
+	}
+
+	return &Translator{
+		model:          chatModel,
+		translateModel: translateModel,
+		responseMode:   config.ResponseMode,
+		modelName:      config.Model,
+		fallbacks:      fallbacks,
+		rejected:       newRejectedModels(),
+		apiRetries:     max(config.APIRetries, 0),
+		context:        "",
+		concurrency:    max(config.Concurrency, 1),
+		limiter:        NewRateLimiter(config.RequestsPerMinute, config.TokensPerMinute),
+		cache:          config.Cache,
+		glossaries:     make(map[string]*Glossary),
+		usage:          NewUsageTracker(),
+	}, nil
+}
+
+// newTranslationModels 创建聊天模型，并按响应模式返回翻译分组使用的模型：
+// tool 模式绑定 submit_translation 工具，json-schema 模式要求按 JSON Schema 输出，text 模式直接使用聊天模型
+func newTranslationModels(ctx context.Context, providerName string, config ProviderConfig, mode string) (chatModel model.ToolCallingChatModel, translateModel model.ToolCallingChatModel, err error) {
+	chatModel, err = NewChatModel(ctx, providerName, config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create chat model: %w", err)
+	}
+
+	switch mode {
+	case ResponseModeText:
+		return chatModel, chatModel, nil
+	case ResponseModeJSONSchema:
+		config.ResponseSchema, err = translationSchema()
+		if err != nil {
+			return nil, nil, err
+		}
+		translateModel, err = NewChatModel(ctx, providerName, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create chat model: %w", err)
+		}
+		return chatModel, translateModel, nil
 	}
 
 	validateTool := &SubmitTranslationTool{}
@@ -139,18 +319,206 @@ func NewTranslator(ctx context.Context, apiKey string, baseURL string, modelName
 		validateTool.Info(),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to bind tools: %w", err)
+		return nil, nil, fmt.Errorf("failed to bind tools: %w", err)
 	}
+	return toolModel, toolModel, nil
+}
 
-	return &Translator{
-		model:   toolModel,
-		context: "",
-	}, nil
+// fallbackProviderConfig 返回备用模型的模型服务和连接参数。与主模型使用同一模型服务时沿用主模型的 API Key，
+// 没有指定地址时也沿用主模型的地址；其他模型服务从各自的环境变量读取 API Key，使用默认地址
+func (c *TranslatorConfig) fallbackProviderConfig(spec ModelSpec) (string, ProviderConfig, error) {
+	primary := c.Provider
+	if primary == "" {
+		primary = ProviderOpenAI
+	}
+	providerName := spec.Provider
+	if providerName == "" {
+		providerName = primary
+	}
+	config := ProviderConfig{BaseURL: spec.BaseURL, Model: spec.Model}
+	if providerName == primary {
+		config.APIKey = c.APIKey
+		if config.BaseURL == "" {
+			config.BaseURL = c.BaseURL
+		}
+		return providerName, config, nil
+	}
+
+	p, err := lookupProvider(providerName)
+	if err != nil {
+		return "", ProviderConfig{}, err
+	}
+	if p.KeyEnv != "" {
+		config.APIKey = os.Getenv(p.KeyEnv)
+	}
+	if config.APIKey == "" && p.RequiresKey {
+		return "", ProviderConfig{}, fmt.Errorf("fallback model %s: API key is required, set %s", spec, p.KeyEnv)
+	}
+	return providerName, config, nil
+}
+
+// SetGlossary 设置目标语言的术语表，lang 为空时适用于所有目标语言
+func (t *Translator) SetGlossary(lang string, glossary *Glossary) {
+	if lang != "" {
+		lang = LookupLanguage(lang).Code
+	}
+	t.glossaries[lang] = glossary
+}
+
+// AddGlossary 把术语合并到目标语言的术语表中，已存在的原文术语保持原有译法
+func (t *Translator) AddGlossary(lang string, glossary *Glossary) {
+	if lang != "" {
+		lang = LookupLanguage(lang).Code
+	}
+	existing, ok := t.glossaries[lang]
+	if !ok {
+		t.glossaries[lang] = glossary
+		return
+	}
+	merged := &Glossary{Entries: append([]*GlossaryEntry(nil), existing.Entries...)}
+	for _, entry := range glossary.Entries {
+		if _, ok := merged.Lookup(entry.Source); !ok {
+			merged.Entries = append(merged.Entries, entry)
+		}
+	}
+	t.glossaries[lang] = merged
+}
+
+// lookupGlossary 在适用于目标语言的术语表中查找原文术语
+func (t *Translator) lookupGlossary(target Language, source string) (*GlossaryEntry, bool) {
+	if entry, ok := t.glossaries[""].Lookup(source); ok {
+		return entry, true
+	}
+	return t.glossaries[target.Code].Lookup(source)
+}
+
+// matchGlossary 返回出现在 texts 中、适用于目标语言的术语
+func (t *Translator) matchGlossary(target Language, texts []string) []*GlossaryEntry {
+	matched := t.glossaries[""].Match(texts)
+	if target.Code != "" {
+		matched = append(matched, t.glossaries[target.Code].Match(texts)...)
+	}
+	return matched
+}
+
+// generate 在速率限制器允许后调用模型，并把模型返回的 token 用量计入 stage 阶段。
+// 遇到暂时性错误（429、5xx、连接被重置等）时按退避时间等待后重试，最多重试 t.apiRetries 次
+func (t *Translator) generate(ctx context.Context, stage string, messages []*schema.Message) (*schema.Message, error) {
+	return t.generateWith(ctx, t.model, stage, messages)
+}
+
+// generateWith 与 generate 相同，但使用指定的模型，翻译分组时使用按响应模式配置的 t.translateModel
+func (t *Translator) generateWith(ctx context.Context, chatModel model.ToolCallingChatModel, stage string, messages []*schema.Message) (*schema.Message, error) {
+	var resp *schema.Message
+	for attempt := 0; ; attempt++ {
+		// 按提示词和预计的输出占用 token 额度，请求成功后按模型服务返回的用量修正
+		reservation, err := t.limiter.Wait(ctx, messageTokens(messages)+completionTokens(stage, messages))
+		if err != nil {
+			return nil, err
+		}
+		info := &responseInfo{}
+		resp, err = chatModel.Generate(context.WithValue(ctx, responseInfoKey, info), messages)
+		if err == nil {
+			if resp.ResponseMeta != nil && resp.ResponseMeta.Usage != nil {
+				t.limiter.Settle(reservation, resp.ResponseMeta.Usage.PromptTokens+resp.ResponseMeta.Usage.CompletionTokens)
+			}
+			break
+		}
+		if fatal := fatalAPIError(err, info); fatal != nil {
+			return nil, fatal
+		}
+		if info.RetryAfter > retryMaxDelay && attempt < t.apiRetries {
+			return nil, fmt.Errorf("%w (server asked to retry after %s, longer than the %s limit)", err, info.RetryAfter, retryMaxDelay)
+		}
+		if attempt >= t.apiRetries || !isTransientError(ctx, err, info) {
+			return nil, err
+		}
+
+		delay := retryDelay(attempt+1, info)
+		logTranslate.Warn("模型请求失败，稍后重试", "transient API error, retrying", "model", t.modelName, "stage", stage, "attempt", attempt+1, "delay", delay, "err", err)
+		t.emit(Event{Type: EventAPIRetry, Stage: stage, Attempt: attempt + 1, Reason: err.Error(), DelaySeconds: delay.Seconds()})
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+
+	var usage *schema.TokenUsage
+	if resp.ResponseMeta != nil {
+		usage = resp.ResponseMeta.Usage
+	}
+	t.usage.Add(t.modelName, stage, usage)
+	return resp, nil
+}
+
+// generateWithFallback 与 generate 相同，但模型服务拒绝请求（*FatalAPIError）时依次换用备用模型，
+// 被拒绝的模型在本次运行中不再使用。只有所有模型都拒绝请求时才返回 *FatalAPIError
+func (t *Translator) generateWithFallback(ctx context.Context, stage string, messages []*schema.Message) (*schema.Message, error) {
+	var fatalErr error
+	for i, m := range t.modelChain() {
+		if err := t.rejected.get(i); err != nil {
+			fatalErr = err
+			continue
+		}
+		resp, err := t.withModel(m).generate(ctx, stage, messages)
+		var fatal *FatalAPIError
+		if !errors.As(err, &fatal) {
+			return resp, err
+		}
+		logTranslate.Error("模型服务拒绝请求，不再使用该模型", "the provider rejected the request, disabling the model", "model", m.name, "stage", stage, "status", fatal.StatusCode, "err", err)
+		t.rejected.mark(i, err)
+		fatalErr = err
+	}
+	return nil, fatalErr
+}
+
+// emit 把事件交给 t.events
+func (t *Translator) emit(e Event) {
+	if t.events != nil {
+		t.events(e)
+	}
+}
+
+// Usage 返回按价格表计算的用量和费用汇总
+func (t *Translator) Usage(prices map[string]ModelPrice) *UsageSummary {
+	return t.usage.Summary(prices)
 }
 
 func (t *Translator) SummarizeContext(ctx context.Context, filename string, subtitles []*SubtitleItem) error {
-	log.Printf("[背景信息] 开始总结电影背景信息")
+	logSummary.Info("开始总结电影背景信息", "summarizing background information")
 
+	sampleText := summarySample(subtitles)
+
+	// 背景信息也写入缓存，保证重新运行时翻译缓存键保持一致
+	cacheKey := translationCacheKey(t.modelName, promptVersion, "summary", filepath.Base(filename), "", []string{sampleText})
+	if cached, ok := t.cache.Get(cacheKey); ok && len(cached) == 1 {
+		t.context = cached[0]
+		logSummary.Info("命中缓存", "cache hit", "summary", sensitive(t.context))
+		return nil
+	}
+
+	messages := summaryMessages(filename, sampleText)
+
+	resp, err := t.generateWithFallback(ctx, StageSummary, messages)
+	if err != nil {
+		logSummary.Error("总结失败", "summary failed", "err", err)
+		return fmt.Errorf("failed to summarize context: %w", err)
+	}
+
+	t.context = string(resp.Content)
+	err = t.cache.Put(cacheKey, translationCacheEntry{
+		Model:        t.modelName,
+		Texts:        []string{sampleText},
+		Translations: []string{t.context},
+	})
+	if err != nil {
+		logSummary.Warn("写入缓存失败", "failed to write cache", "err", err)
+	}
+	logSummary.Info("背景信息总结完成", "background information summarized", "summary", sensitive(t.context))
+	return nil
+}
+
+// summarySample 返回总结背景信息使用的字幕样本，即字幕开头不超过 20000 字符的部分
+func summarySample(subtitles []*SubtitleItem) string {
 	sampleText := ""
 	maxChars := 20000
 	for _, item := range subtitles {
@@ -162,194 +530,564 @@ func (t *Translator) SummarizeContext(ctx context.Context, filename string, subt
 		}
 		sampleText += item.Text
 	}
+	return sampleText
+}
 
-	messages := []*schema.Message{
+func summaryMessages(filename string, sampleText string) []*schema.Message {
+	return []*schema.Message{
 		schema.SystemMessage(summarizePrompt),
 		schema.UserMessage(fmt.Sprintf("Filename: %s\n\nSubtitle samples:\n%s", filename, sampleText)),
 	}
-
-	resp, err := t.model.Generate(ctx, messages)
-	if err != nil {
-		log.Printf("[背景信息] 总结失败: %v", err)
-		return fmt.Errorf("failed to summarize context: %w", err)
-	}
-
-	t.context = string(resp.Content)
-	log.Printf("[背景信息] 背景信息总结: %s", t.context)
-	return nil
 }
 
 type SubtitleGroup struct {
+	// ID 是分组在整个字幕中的序号，用于断点续传时识别分组
+	ID      int
 	Indices []int
 	Texts   []string
+
+	// PrevTexts 和 PrevTranslations 是上一组末尾的原文和已采用的译文，NextTexts 是下一组开头的原文。
+	// 它们只作为只读上下文提供给模型，不计入提交的译文条数
+	PrevTexts        []string
+	PrevTranslations []string
+	NextTexts        []string
+}
+
+// GroupDoneFunc 在分组翻译完成或失败后调用，失败时 translations 为 nil、report.Error 为失败原因，
+// 可能被多个 goroutine 并发调用
+type GroupDoneFunc func(group SubtitleGroup, translations []string, report *GroupReport)
+
+// GroupOptions 控制字幕分组：时间间隔不超过 MaxGapSeconds 的字幕归为一组，
+// 超过 MaxCues 条或估算 token 数超过 MaxTokens 的组会在句子边界处拆开。
+// MaxCues 为 0 表示不限制；MaxTokens 为 0 表示使用模型的默认值（见 modelGroupMaxTokens），小于 0 表示不限制
+type GroupOptions struct {
+	MaxGapSeconds float64
+	MaxCues       int
+	MaxTokens     int
+}
+
+// DefaultGroupOptions 是默认的分组参数
+var DefaultGroupOptions = GroupOptions{
+	MaxGapSeconds: 3.0,
+	MaxCues:       40,
+}
+
+// defaultGroupMaxTokens 是未知模型（例如上下文较小的本地模型）每组原文的最大估算 token 数
+const defaultGroupMaxTokens = 1500
+
+// defaultModelGroupMaxTokens 是内置的按模型的每组 token 上限。译文的 token 数通常多于原文，
+// 上限主要受模型的输出长度限制，输出长度较大的模型可以把更长的对话放在一组，减少请求次数
+var defaultModelGroupMaxTokens = map[string]int{
+	"gpt-3.5-turbo": 1000,
+	"gpt-4o":        2500,
+	"gpt-4.1":       3000,
+	"claude":        3000,
+	"gemini":        3000,
+	"deepseek":      2000,
+}
+
+// modelGroupMaxTokens 返回模型默认的每组 token 上限，按最长的前缀匹配 defaultModelGroupMaxTokens，
+// 忽略 openrouter 等服务在模型名前加的 "anthropic/" 这样的前缀，未知模型使用 defaultGroupMaxTokens
+func modelGroupMaxTokens(model string) int {
+	model = strings.ToLower(model[strings.LastIndex(model, "/")+1:])
+	best := ""
+	for name := range defaultModelGroupMaxTokens {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return defaultGroupMaxTokens
+	}
+	return defaultModelGroupMaxTokens[best]
 }
 
 func GroupSubtitlesByTime(items []*SubtitleItem, maxGapSeconds float64) []SubtitleGroup {
+	return GroupSubtitles(items, GroupOptions{MaxGapSeconds: maxGapSeconds})
+}
+
+func GroupSubtitles(items []*SubtitleItem, opts GroupOptions) []SubtitleGroup {
 	if len(items) == 0 {
 		return []SubtitleGroup{}
 	}
 
-	groups := []SubtitleGroup{{Indices: []int{0}, Texts: []string{items[0].Text}}}
-
+	// 先按时间间隔划分出连续的对话，再把超出限制的对话拆成多个组
+	var runs [][]int
+	run := []int{0}
 	for i := 1; i < len(items); i++ {
 		gap := items[i].StartAt - items[i-1].EndAt
-		if gap.Seconds() <= maxGapSeconds {
-			groups[len(groups)-1].Indices = append(groups[len(groups)-1].Indices, i)
-			groups[len(groups)-1].Texts = append(groups[len(groups)-1].Texts, items[i].Text)
+		if gap.Seconds() <= opts.MaxGapSeconds {
+			run = append(run, i)
 		} else {
-			groups = append(groups, SubtitleGroup{
-				Indices: []int{i},
-				Texts:   []string{items[i].Text},
-			})
+			runs = append(runs, run)
+			run = []int{i}
+		}
+	}
+	runs = append(runs, run)
+
+	groups := []SubtitleGroup{}
+	for _, run := range runs {
+		for _, indices := range splitRun(items, run, opts) {
+			group := SubtitleGroup{ID: len(groups), Indices: indices}
+			for _, idx := range indices {
+				group.Texts = append(group.Texts, items[idx].Text)
+			}
+			groups = append(groups, group)
 		}
 	}
 
+	for i := range groups {
+		if i > 0 {
+			groups[i].PrevTexts = lastN(groups[i-1].Texts, prevContextCues)
+		}
+		if i < len(groups)-1 {
+			groups[i].NextTexts = groups[i+1].Texts[:min(nextContextCues, len(groups[i+1].Texts))]
+		}
+	}
 	return groups
 }
 
-func (t *Translator) TranslateGroups(ctx context.Context, groups []SubtitleGroup) (map[int]string, error) {
-	results := make(map[int]string)
+// lastN 返回 s 的最后 n 个元素
+func lastN(s []string, n int) []string {
+	return s[max(len(s)-n, 0):]
+}
 
-	for _, group := range groups {
-		log.Printf("[分组翻译] 翻译包含 %d 条字幕的分组", len(group.Indices))
+// SetPrevTranslations 设置上一组已采用的译文，translations 与上一组的全部原文一一对应
+func (g *SubtitleGroup) SetPrevTranslations(translations []string) {
+	if len(g.PrevTexts) == 0 || len(translations) < len(g.PrevTexts) {
+		return
+	}
+	g.PrevTranslations = lastN(translations, len(g.PrevTexts))
+}
 
-		jsonArray, err := json.Marshal(group.Texts)
-		if err != nil {
-			log.Printf("[分组翻译] JSON序列化失败: %v", err)
-			return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+// formatNeighbourContext 把前后文格式化为提示词的一部分，没有前后文时返回空字符串
+func formatNeighbourContext(group SubtitleGroup) string {
+	var builder strings.Builder
+	for i, text := range group.PrevTexts {
+		builder.WriteString("- 前文: " + text)
+		if i < len(group.PrevTranslations) {
+			builder.WriteString(" => " + group.PrevTranslations[i])
 		}
+		builder.WriteString("\n")
+	}
+	if len(group.PrevTexts) > 0 && len(group.NextTexts) > 0 {
+		builder.WriteString("- （本组字幕）\n")
+	}
+	for _, text := range group.NextTexts {
+		builder.WriteString("- 后文: " + text + "\n")
+	}
+	return builder.String()
+}
 
-		systemPrompt := fmt.Sprintf(translatePrompt, len(group.Texts), len(group.Texts))
-		if t.context != "" {
-			systemPrompt = fmt.Sprintf(translateWithContextPrompt, len(group.Texts), len(group.Texts), t.context)
+// splitRun 把一段连续的对话拆成不超过条数和 token 限制的若干段
+func splitRun(items []*SubtitleItem, run []int, opts GroupOptions) [][]int {
+	var parts [][]int
+	for start := 0; start < len(run); {
+		end := start
+		tokens := 0
+		for end < len(run) {
+			if opts.MaxCues > 0 && end-start >= opts.MaxCues {
+				break
+			}
+			cueTokens := estimateTokens(items[run[end]].Text)
+			if opts.MaxTokens > 0 && end > start && tokens+cueTokens > opts.MaxTokens {
+				break
+			}
+			tokens += cueTokens
+			end++
 		}
+		if end < len(run) {
+			end = start + splitPoint(items, run[start:end+1])
+		}
+		parts = append(parts, run[start:end])
+		start = end
+	}
+	return parts
+}
+
+// splitPoint 在 window[:len(window)-1] 中选择拆分位置（window 的最后一条是放不下的下一条字幕），
+// 返回前一段的条数。优先在后半段中最后一个以句末标点结尾的字幕之后拆分，
+// 没有时在后半段中间隔最长的两条字幕之间拆分
+func splitPoint(items []*SubtitleItem, window []int) int {
+	size := len(window) - 1
+	if size <= 1 {
+		return size
+	}
 
-		messages := []*schema.Message{
-			schema.SystemMessage(systemPrompt),
-			schema.UserMessage(string(jsonArray)),
+	for k := size; k > size/2; k-- {
+		if endsSentence(items[window[k-1]].Text) {
+			return k
 		}
+	}
 
-		var translations []string
-		maxRetries := 3
+	best := size
+	var bestGap time.Duration = -1
+	for k := size; k > size/2; k-- {
+		gap := items[window[k]].StartAt - items[window[k-1]].EndAt
+		if gap > bestGap {
+			best = k
+			bestGap = gap
+		}
+	}
+	return best
+}
 
-	outer:
-		for retry := 0; retry < maxRetries; retry++ {
-			if retry > 0 {
-				log.Printf("[分组翻译] 第 %d 次重试", retry)
-			}
+// endsSentence 判断字幕是否以句末标点结尾，忽略结尾的引号和括号
+func endsSentence(text string) bool {
+	text = strings.TrimRight(text, " \t\n\"'”’)）」』]")
+	if text == "" {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(text)
+	return strings.ContainsRune(".!?。！？…♪", r)
+}
 
-			// 将 expectedCount 存入 context
-			ctxWithUserData := context.WithValue(ctx, submitTranslationUserdataKey, &SubmitTranslationUserdata{
-				ExpectedCount: len(group.Indices),
-			})
+// TranslateGroups 翻译所有分组，返回字幕序号到译文的映射。某个分组在所有模型上都失败时不会中止运行，
+// 该分组以原文代替并在 report.Error 中记录原因，由调用方决定如何处理。
+// 所有模型都拒绝请求（*FatalAPIError，例如 API Key 无效）时通过 context 取消其他分组并返回该错误，ctx 被取消时也返回错误
+func (t *Translator) TranslateGroups(ctx context.Context, groups []SubtitleGroup, sourceLang string, targetLang string, onGroupDone GroupDoneFunc) (map[int]string, error) {
+	source := LookupLanguage(sourceLang)
+	target := LookupLanguage(targetLang)
 
-			log.Printf("[分组翻译] 原始输入: %s", string(jsonArray))
-			resp, err := t.model.Generate(ctxWithUserData, messages)
-			if err != nil {
-				log.Printf("[分组翻译] 翻译失败: %v", err)
-				return nil, fmt.Errorf("failed to translate group: %w", err)
-			}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
-			log.Printf("[分组翻译] 响应内容: %s", string(resp.Content))
-			log.Printf("[分组翻译] ToolCalls 数据: %s", tojson(resp.ToolCalls))
-
-			// 处理tool_call，通过tool_call拿到翻译结果
-			if len(resp.ToolCalls) > 0 {
-				for _, toolCall := range resp.ToolCalls {
-					if toolCall.Function.Name == "submit_translation" {
-						var input SubmitTranslationReq
-						if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &input); err != nil {
-							log.Printf("[分组翻译] ToolCall 参数解析失败: %v", err)
-							if retry < maxRetries-1 {
-								messages = append(messages, schema.AssistantMessage(string(resp.Content), resp.ToolCalls))
-								messages = append(messages, schema.UserMessage("翻译提交失败，请重新提交。"))
-								continue outer
-							}
-							return nil, fmt.Errorf("failed to parse tool call arguments: %w", err)
-						}
-
-						log.Printf("[分组翻译] 收到翻译结果，共 %d 条", len(input.Translations))
-						translations = input.Translations
-
-						// 调用工具验证
-						validateTool := &SubmitTranslationTool{}
-						toolResult, err := validateTool.InvokableRun(ctxWithUserData, toolCall.Function.Arguments)
-						if err != nil {
-							log.Printf("[分组翻译] 工具调用失败: %v", err)
-							if retry < maxRetries-1 {
-								messages = append(messages, schema.AssistantMessage(string(resp.Content), resp.ToolCalls))
-								messages = append(messages, schema.ToolMessage(string(toolResult), toolCall.ID))
-								messages = append(messages, schema.UserMessage("工具调用错误，请重新提交翻译。"))
-								continue outer
-							}
-							return nil, fmt.Errorf("tool invocation failed: %w", err)
-						}
-
-						var validateOutput SubmitTranslationResp
-						if err := json.Unmarshal([]byte(toolResult), &validateOutput); err != nil {
-							log.Printf("[分组翻译] 验证结果解析失败: %v", err)
-							if retry < maxRetries-1 {
-								messages = append(messages, schema.AssistantMessage(string(resp.Content), resp.ToolCalls))
-								messages = append(messages, schema.ToolMessage(string(toolResult), toolCall.ID))
-								messages = append(messages, schema.UserMessage("验证结果解析失败，请重新提交翻译。"))
-								continue outer
-							}
-							return nil, fmt.Errorf("failed to parse validation result: %w", err)
-						}
-
-						if validateOutput.Valid {
-							log.Printf("[分组翻译] 验证通过")
-							break outer
-						} else {
-							log.Printf("[分组翻译] 验证失败: %s", validateOutput.Reason)
-							if retry < maxRetries-1 {
-								messages = append(messages, schema.AssistantMessage(string(resp.Content), resp.ToolCalls))
-								messages = append(messages, schema.ToolMessage(validateOutput.Reason, toolCall.ID))
-								messages = append(messages, schema.UserMessage(validateOutput.Reason+" 请重新翻译并提交。"))
-								continue outer
-							}
-						}
-					}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[int]string)
+		jobs    = make(chan int)
+		// accepted 保存本次已完成分组的译文，作为下一组的前文
+		accepted = make(map[int][]string)
+	)
+
+	workers := min(t.concurrency, len(groups))
+	// 只有逐组翻译时上一组一定已经完成，才把本次得到的译文作为前文；并发翻译时上一组通常还在进行，
+	// 是否赶得上取决于调度，会让同样的输入得到不同的提示词，因此只提供上一组的原文（断点日志中恢复的译文除外）
+	sequential := workers == 1
+	logTranslate.Info("开始翻译分组", "translating groups", "groups", len(groups), "workers", workers, "target_lang", targetLang)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				group := groups[i]
+				mu.Lock()
+				if prev, ok := accepted[group.ID-1]; ok && sequential && len(group.PrevTranslations) == 0 {
+					group.SetPrevTranslations(prev)
 				}
-				break
-			} else {
-				// 没有使用 tool_call，尝试从响应中解析
-				log.Printf("[分组翻译] 未检测到 tool_call，尝试从响应中解析")
-				err := json.Unmarshal([]byte(resp.Content), &translations)
+				mu.Unlock()
+
+				groupID := group.ID
+				t.emit(Event{Type: EventGroupStarted, TargetLang: targetLang, Group: &groupID, Cues: len(group.Indices)})
+				report := newGroupReport(group)
+				report.TargetLang = targetLang
+				translations, err := t.translateGroupWithFallback(ctx, group, source, target, report)
 				if err != nil {
-					log.Printf("[分组翻译] JSON解析失败: %v", err)
-					if retry < maxRetries-1 {
-						messages = append(messages, schema.AssistantMessage(string(resp.Content), resp.ToolCalls))
-						messages = append(messages, schema.UserMessage("请使用 submit_translation 工具提交翻译结果。"))
-						continue outer
+					if context.Cause(ctx) != nil {
+						continue
 					}
-					return nil, fmt.Errorf("failed to parse translation: %w", err)
+					var fatal *FatalAPIError
+					if errors.As(err, &fatal) {
+						logTranslate.Error("所有模型都拒绝请求，停止翻译", "every model was rejected by its provider, stopping", "group", group.ID, "status", fatal.StatusCode, "err", err)
+						cancel(err)
+						continue
+					}
+					logTranslate.Error("分组翻译失败，使用原文代替", "group failed, falling back to the source text", "group", group.ID, "err", err)
+					report.Error = err.Error()
+					translations = nil
 				}
 
-				if len(translations) != len(group.Indices) {
-					log.Printf("[分组翻译] 警告: 翻译后数组长度 %d 与输入数组长度 %d 不匹配", len(translations), len(group.Indices))
-					if retry < maxRetries-1 {
-						messages = append(messages, schema.AssistantMessage(string(resp.Content), resp.ToolCalls))
-						messages = append(messages, schema.UserMessage(fmt.Sprintf("翻译数组长度不匹配。请使用 submit_translation 工具重新提交，确保数组里每一个输入都对应一个输出。")))
-						continue outer
+				mu.Lock()
+				if len(translations) == len(group.Indices) {
+					accepted[group.ID] = translations
+				}
+				for i, idx := range group.Indices {
+					if i < len(translations) {
+						results[idx] = translations[i]
+					} else {
+						results[idx] = group.Texts[i]
+						report.FallbackCues = append(report.FallbackCues, idx+1)
 					}
 				}
-				break outer
+				mu.Unlock()
+				if len(report.FallbackCues) > 0 && report.Error == "" {
+					logTranslate.Warn("部分字幕没有得到译文，使用原文代替", "some cues were not translated, falling back to the source text", "group", group.ID, "fallback_cues", len(report.FallbackCues))
+				}
+
+				if onGroupDone != nil {
+					onGroupDone(group, translations, report)
+				}
+				if report.Error == "" {
+					logTranslate.Info("分组翻译完成", "group translated", "group", group.ID)
+				}
 			}
+		}()
+	}
+
+feed:
+	for i := range groups {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
 		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
 
-		for i, idx := range group.Indices {
-			if i < len(translations) {
-				results[idx] = translations[i]
+// translateGroupWithFallback 先用主模型翻译分组，请求出错或重试用尽仍没有得到完整译文时依次换用备用模型，
+// 最终采用的译文来自哪个模型记录在 report.Model 中。所有模型都没有得到完整译文时，采用得到译文最多的结果。
+// 模型服务拒绝请求（*FatalAPIError）的模型在本次运行中不再使用，只有所有模型都拒绝请求时才返回 *FatalAPIError
+func (t *Translator) translateGroupWithFallback(ctx context.Context, group SubtitleGroup, source Language, target Language, report *GroupReport) ([]string, error) {
+	report.Model = t.modelName
+	var (
+		best      []string
+		bestValid bool
+		found     bool
+		groupErr  error
+		fatalErr  error
+		// lastModel 和 lastReason 是上一个没有得到完整译文的模型及原因，换用下一个模型时随事件发出
+		lastModel, lastReason string
+	)
+	for i, m := range t.modelChain() {
+		if context.Cause(ctx) != nil {
+			break
+		}
+		if err := t.rejected.get(i); err != nil {
+			fatalErr = err
+			lastModel, lastReason = m.name, "skipped, the provider rejected an earlier request: "+err.Error()
+			report.ModelFailures = append(report.ModelFailures, lastModel+": "+lastReason)
+			continue
+		}
+		if lastModel != "" {
+			logTranslate.Warn("换用备用模型", "falling back to the next model", "group", group.ID, "from", lastModel, "to", m.name, "reason", lastReason)
+			groupID := group.ID
+			t.emit(Event{Type: EventModelFallback, TargetLang: report.TargetLang, Group: &groupID, Model: m.name, Reason: lastReason})
+		}
+
+		translations, err := t.withModel(m).translateGroup(ctx, group, source, target, report)
+		var fatal *FatalAPIError
+		switch {
+		case errors.As(err, &fatal):
+			logTranslate.Error("模型服务拒绝请求，不再使用该模型", "the provider rejected the request, disabling the model", "group", group.ID, "model", m.name, "status", fatal.StatusCode, "err", err)
+			t.rejected.mark(i, err)
+			fatalErr = err
+		case err != nil:
+			if groupErr == nil {
+				groupErr = err
+			}
+		case !found || len(translations) > len(best):
+			best, found = translations, true
+			bestValid = report.valid
+			report.Model = m.name
+		}
+
+		if err == nil && len(translations) == len(group.Indices) {
+			break
+		}
+		lastModel, lastReason = m.name, fmt.Sprintf("got %d translations, expected %d", len(translations), len(group.Indices))
+		if err != nil {
+			lastReason = err.Error()
+		}
+		report.ModelFailures = append(report.ModelFailures, lastModel+": "+lastReason)
+	}
+
+	report.valid = bestValid
+	if found {
+		return best, nil
+	}
+	if groupErr != nil {
+		return nil, groupErr
+	}
+	return nil, fatalErr
+}
+
+// modelChain 返回按使用顺序排列的模型：主模型在前，备用模型在后
+func (t *Translator) modelChain() []fallbackModel {
+	chain := []fallbackModel{{model: t.model, translateModel: t.translateModel, name: t.modelName}}
+	return append(chain, t.fallbacks...)
+}
+
+// withModel 返回改用模型 m 的 Translator 副本，其余状态（缓存、用量、速率限制等）与 t 共享
+func (t *Translator) withModel(m fallbackModel) *Translator {
+	candidate := *t
+	candidate.model = m.model
+	candidate.translateModel = m.translateModel
+	candidate.modelName = m.name
+	return &candidate
+}
+
+// translateGroup 翻译单个分组，返回与 group.Texts 一一对应的译文（可能少于输入条数），翻译过程记录在 report 中
+func (t *Translator) translateGroup(ctx context.Context, group SubtitleGroup, source Language, target Language, report *GroupReport) ([]string, error) {
+	logTranslate.Debug("开始翻译分组", "translating group", "group", group.ID, "cues", len(group.Indices), "source_lang", source.Code, "target_lang", target.Code)
+
+	glossary := t.matchGlossary(target, group.Texts)
+	glossaryText := formatGlossaryPrompt(glossary)
+
+	// 术语表会改变提示词，因此和背景信息一起参与缓存键计算；前后文只作参考，不参与计算，
+	// 以免并发翻译时上一组是否已完成影响缓存命中
+	cacheKey := translationCacheKey(t.modelName, promptVersion, t.context+glossaryText, source.Code, target.Code, group.Texts)
+	if cached, ok := t.cache.Get(cacheKey); ok {
+		logTranslate.Debug("命中翻译缓存", "cache hit", "group", group.ID)
+		report.Method = GroupMethodCache
+		report.valid = true
+		return cached, nil
+	}
+
+	jsonArray, err := json.Marshal(group.Texts)
+	if err != nil {
+		logTranslate.Error("JSON序列化失败", "failed to marshal JSON", "group", group.ID, "err", err)
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if len(glossary) > 0 {
+		logTranslate.Debug("匹配到术语", "glossary terms matched", "group", group.ID, "terms", len(glossary))
+	}
+	systemPrompt := translateSystemPrompt(group, source, target, t.context, glossaryText, t.responseMode)
+
+	messages := []*schema.Message{
+		schema.SystemMessage(systemPrompt),
+		schema.UserMessage(string(jsonArray)),
+	}
+
+	var translations []string
+	// lastReason 是最后一次提交没有通过校验的原因，为空表示译文通过了校验
+	var lastReason string
+	maxRetries := 3
+
+	for retry := 0; retry < maxRetries; retry++ {
+		if retry > 0 {
+			logTranslate.Info("重试翻译", "retrying translation", "group", group.ID, "attempt", retry+1)
+			var reason string
+			if n := len(report.ValidationFailures); n > 0 {
+				reason = report.ValidationFailures[n-1]
+			}
+			groupID := group.ID
+			t.emit(Event{Type: EventRetry, TargetLang: report.TargetLang, Group: &groupID, Attempt: retry + 1, Reason: reason})
+		}
+		report.Attempts++
+
+		// 将 expectedCount 存入 context
+		ctxWithUserData := context.WithValue(ctx, submitTranslationUserdataKey, &SubmitTranslationUserdata{
+			ExpectedCount: len(group.Indices),
+			Sources:       group.Texts,
+			Glossary:      glossary,
+		})
+
+		stage := StageTranslate
+		if retry > 0 {
+			stage = StageTranslateRetry
+		}
+		logTranslate.Debug("请求内容", "request payload", "group", group.ID, "texts", sensitive(string(jsonArray)))
+		resp, err := t.generateWith(ctxWithUserData, t.translateModel, stage, messages)
+		if err != nil {
+			logTranslate.Error("翻译失败", "translation request failed", "group", group.ID, "err", err)
+			return nil, fmt.Errorf("failed to translate group: %w", err)
+		}
+
+		logTranslate.Debug("响应内容", "response payload", "group", group.ID, "content", sensitive(resp.Content), "tool_calls", sensitive(tojson(resp.ToolCalls)))
+
+		// 从工具调用或回复内容中取出译文，三种响应模式都用 submit_translation 工具的校验逻辑检查
+		sub, err := extractSubmission(resp, t.responseMode)
+		report.Method = sub.Method
+		var reason string
+		if err != nil {
+			logTranslate.Warn("译文解析失败", "failed to parse translations", "group", group.ID, "method", sub.Method, "err", err)
+			reason = fmt.Sprintf("invalid response: %v", err)
+		} else {
+			var input SubmitTranslationReq
+			if err := json.Unmarshal([]byte(sub.Arguments), &input); err != nil {
+				logTranslate.Warn("译文参数解析失败", "failed to parse submitted arguments", "group", group.ID, "method", sub.Method, "err", err)
+				reason = fmt.Sprintf("invalid submitted arguments: %v", err)
 			} else {
-				results[idx] = group.Texts[i]
+				logTranslate.Debug("收到翻译结果", "translations received", "group", group.ID, "translations", len(input.Translations))
+				translations = input.Translations
+				reason = t.validateSubmission(ctxWithUserData, group, sub.Arguments)
+				if reason == "" {
+					logTranslate.Debug("验证通过", "validation passed", "group", group.ID)
+					lastReason = ""
+					break
+				}
+				logTranslate.Warn("验证失败", "validation failed", "group", group.ID, "reason", sensitive(reason))
 			}
 		}
 
-		log.Printf("[分组翻译] 分组翻译完成")
+		lastReason = reason
+		report.ValidationFailures = append(report.ValidationFailures, reason)
+		if retry < maxRetries-1 {
+			messages = append(messages, schema.AssistantMessage(resp.Content, resp.ToolCalls))
+			messages = append(messages, toolReplies(resp.ToolCalls, sub.ToolCall, reason)...)
+			messages = append(messages, schema.UserMessage(reason+" 请重新翻译并提交。"))
+			continue
+		}
+		// 重试用尽仍无法解析出译文时返回错误，解析出但没有通过校验的译文照常返回，缺少的字幕以原文代替
+		if translations == nil {
+			return nil, fmt.Errorf("failed to parse translation: %s", reason)
+		}
 	}
 
-	return results, nil
+	// 只缓存通过校验的译文，没有通过校验的译文下次运行时重新翻译
+	report.valid = lastReason == ""
+	if report.valid {
+		err := t.cache.Put(cacheKey, translationCacheEntry{
+			Model:        t.modelName,
+			SourceLang:   source.Code,
+			TargetLang:   target.Code,
+			Texts:        group.Texts,
+			Translations: translations,
+		})
+		if err != nil {
+			logTranslate.Warn("写入翻译缓存失败", "failed to write translation cache", "group", group.ID, "err", err)
+		}
+	}
+
+	return translations, nil
+}
+
+// validateSubmission 用 submit_translation 工具检查提交的译文，通过时返回空字符串，否则返回失败原因
+func (t *Translator) validateSubmission(ctx context.Context, group SubtitleGroup, arguments string) string {
+	validateTool := &SubmitTranslationTool{}
+	toolResult, err := validateTool.InvokableRun(ctx, arguments)
+	if err != nil {
+		logTranslate.Warn("工具调用失败", "tool invocation failed", "group", group.ID, "err", err)
+		return fmt.Sprintf("tool invocation failed: %v", err)
+	}
+	var validateOutput SubmitTranslationResp
+	if err := json.Unmarshal([]byte(toolResult), &validateOutput); err != nil {
+		logTranslate.Warn("验证结果解析失败", "failed to parse validation result", "group", group.ID, "err", err)
+		return fmt.Sprintf("invalid validation result: %v", err)
+	}
+	if validateOutput.Valid {
+		return ""
+	}
+	return validateOutput.Reason
+}
+
+// translateSystemPrompt 生成翻译一个分组的系统提示词
+func translateSystemPrompt(group SubtitleGroup, source Language, target Language, context string, glossaryText string, mode string) string {
+	submit, ok := submitPrompts[mode]
+	if !ok {
+		submit = submitPrompts[ResponseModeTool]
+	}
+	systemPrompt := fmt.Sprintf(translatePrompt, len(group.Texts), source.Name, target.Name, submit.Notice, submit.Check, len(group.Texts))
+	if context != "" {
+		systemPrompt = fmt.Sprintf(translateWithContextPrompt, len(group.Texts), source.Name, target.Name, submit.Notice, submit.Check, len(group.Texts), context)
+	}
+	if glossaryText != "" {
+		systemPrompt += fmt.Sprintf(glossaryPrompt, glossaryText)
+	}
+	if neighbours := formatNeighbourContext(group); neighbours != "" {
+		systemPrompt += fmt.Sprintf(neighbourContextPrompt, neighbours)
+	}
+	return systemPrompt
 }
 
 func tojson(v interface{}) string {
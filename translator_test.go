@@ -0,0 +1,561 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// fakeChatModel 是测试用的聊天模型，每次请求调用 generate
+type fakeChatModel struct {
+	generate func(messages []*schema.Message) (*schema.Message, error)
+}
+
+func (m *fakeChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return m.generate(input)
+}
+
+func (m *fakeChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return singleMessageStream(m.Generate(ctx, input, opts...))
+}
+
+func (m *fakeChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// newTestTranslator 创建使用 fake 模型的翻译器，不做 API 重试
+func newTestTranslator(generate func(messages []*schema.Message) (*schema.Message, error), concurrency int) *Translator {
+	chatModel := &fakeChatModel{generate: generate}
+	return &Translator{
+		model:          chatModel,
+		translateModel: chatModel,
+		responseMode:   ResponseModeTool,
+		modelName:      "fake",
+		rejected:       newRejectedModels(),
+		concurrency:    concurrency,
+		glossaries:     make(map[string]*Glossary),
+		usage:          NewUsageTracker(),
+	}
+}
+
+// groupTexts 返回翻译请求中要翻译的字幕原文
+func groupTexts(messages []*schema.Message) []string {
+	var texts []string
+	for _, msg := range messages {
+		if msg.Role == schema.User && json.Unmarshal([]byte(msg.Content), &texts) == nil {
+			return texts
+		}
+	}
+	return nil
+}
+
+// submitTranslations 返回调用 submit_translation 提交 "T:" 加原文的回复
+func submitTranslations(messages []*schema.Message) (*schema.Message, error) {
+	texts := groupTexts(messages)
+	translations := make([]string, len(texts))
+	for i, text := range texts {
+		translations[i] = "T:" + text
+	}
+	arguments, err := json.Marshal(SubmitTranslationReq{Translations: translations})
+	if err != nil {
+		return nil, err
+	}
+	return schema.AssistantMessage("", []schema.ToolCall{{
+		ID:       "call_0",
+		Type:     "function",
+		Function: schema.FunctionCall{Name: "submit_translation", Arguments: string(arguments)},
+	}}), nil
+}
+
+// testGroups 生成 n 个分组，每组两条字幕，组与组之间间隔 10 秒
+func testGroups(n int) []SubtitleGroup {
+	var items []*SubtitleItem
+	for g := 0; g < n; g++ {
+		for c := 0; c < 2; c++ {
+			start := time.Duration(g*10+c) * time.Second
+			items = append(items, &SubtitleItem{
+				Index:   len(items) + 1,
+				StartAt: start,
+				EndAt:   start + 500*time.Millisecond,
+				Text:    fmt.Sprintf("group %d line %d.", g, c),
+			})
+		}
+	}
+	return GroupSubtitles(items, DefaultGroupOptions)
+}
+
+func TestTranslateGroupsPrevContext(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		var mu sync.Mutex
+		prompts := make(map[string]string)
+		translator := newTestTranslator(func(messages []*schema.Message) (*schema.Message, error) {
+			mu.Lock()
+			prompts[groupTexts(messages)[0]] = messages[0].Content
+			mu.Unlock()
+			return submitTranslations(messages)
+		}, concurrency)
+
+		groups := testGroups(6)
+		if _, err := translator.TranslateGroups(context.Background(), groups, "en", "zh", nil); err != nil {
+			t.Fatalf("concurrency %d: TranslateGroups: %v", concurrency, err)
+		}
+
+		for _, group := range groups[1:] {
+			prompt := prompts[group.Texts[0]]
+			prevLine := "- 前文: " + group.PrevTexts[0]
+			if !strings.Contains(prompt, prevLine) {
+				t.Errorf("concurrency %d: group %d prompt has no previous source line", concurrency, group.ID)
+			}
+			// 逐组翻译时总带上一组的译文，并发翻译时总不带，不随调度变化
+			withTranslation := strings.Contains(prompt, prevLine+" => T:"+group.PrevTexts[0])
+			if want := concurrency == 1; withTranslation != want {
+				t.Errorf("concurrency %d: group %d prompt includes previous translation = %v, want %v", concurrency, group.ID, withTranslation, want)
+			}
+		}
+	}
+}
+
+func TestTranslateGroupsRestoredPrevContext(t *testing.T) {
+	var mu sync.Mutex
+	prompts := make(map[string]string)
+	translator := newTestTranslator(func(messages []*schema.Message) (*schema.Message, error) {
+		mu.Lock()
+		prompts[groupTexts(messages)[0]] = messages[0].Content
+		mu.Unlock()
+		return submitTranslations(messages)
+	}, 4)
+
+	// 断点续传时上一组的译文在运行前已经确定，并发翻译时也会提供
+	groups := testGroups(2)
+	groups[1].SetPrevTranslations([]string{"恢复 0", "恢复 1"})
+	if _, err := translator.TranslateGroups(context.Background(), groups[1:], "en", "zh", nil); err != nil {
+		t.Fatalf("TranslateGroups: %v", err)
+	}
+	if prompt := prompts[groups[1].Texts[0]]; !strings.Contains(prompt, "=> 恢复 0") {
+		t.Errorf("prompt does not include the restored translation:\n%s", prompt)
+	}
+}
+
+func TestTranslateGroupsKeepsGoingWhenGroupsFail(t *testing.T) {
+	groups := testGroups(3)
+	translator := newTestTranslator(func(messages []*schema.Message) (*schema.Message, error) {
+		if groupTexts(messages)[0] == groups[1].Texts[0] {
+			return schema.AssistantMessage("I cannot translate this.", nil), nil
+		}
+		return submitTranslations(messages)
+	}, 2)
+
+	// 失败的分组由调用方处理，即使本次只翻译失败的分组（retry-failed）也不返回错误
+	var mu sync.Mutex
+	reports := make(map[int]*GroupReport)
+	onGroupDone := func(group SubtitleGroup, translations []string, report *GroupReport) {
+		mu.Lock()
+		reports[group.ID] = report
+		mu.Unlock()
+	}
+	for _, run := range [][]SubtitleGroup{groups, groups[1:2]} {
+		results, err := translator.TranslateGroups(context.Background(), run, "en", "zh", onGroupDone)
+		if err != nil {
+			t.Fatalf("TranslateGroups(%d groups): %v", len(run), err)
+		}
+		for _, group := range run {
+			for i, idx := range group.Indices {
+				want := "T:" + group.Texts[i]
+				if group.ID == 1 {
+					want = group.Texts[i]
+				}
+				if results[idx] != want {
+					t.Errorf("cue %d = %q, want %q", idx, results[idx], want)
+				}
+			}
+		}
+		if reports[1].Error == "" || reports[0].Error != "" {
+			t.Errorf("group errors = %q, %q, want only group 1 to fail", reports[0].Error, reports[1].Error)
+		}
+	}
+}
+
+func TestTranslateGroupsFallsBackOnFatalAPIError(t *testing.T) {
+	var calls, fallbackCalls atomic.Int64
+	translator := newTestTranslator(func(messages []*schema.Message) (*schema.Message, error) {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil, &APIError{Provider: ProviderOpenAI, StatusCode: http.StatusUnauthorized, Message: "invalid api key"}
+	}, 2)
+	fallback := &fakeChatModel{generate: func(messages []*schema.Message) (*schema.Message, error) {
+		fallbackCalls.Add(1)
+		return submitTranslations(messages)
+	}}
+	translator.fallbacks = []fallbackModel{{model: fallback, translateModel: fallback, name: "fallback"}}
+
+	var mu sync.Mutex
+	var models []string
+	groups := testGroups(10)
+	results, err := translator.TranslateGroups(context.Background(), groups, "en", "zh", func(group SubtitleGroup, translations []string, report *GroupReport) {
+		mu.Lock()
+		defer mu.Unlock()
+		models = append(models, report.Model)
+	})
+	if err != nil {
+		t.Fatalf("TranslateGroups: %v", err)
+	}
+	// 401 只让主模型不再使用：每个 worker 最多向主模型发出一个请求，之后的分组直接使用备用模型
+	if n := calls.Load(); n > 2 {
+		t.Errorf("sent %d requests to the primary model after a 401, want at most one per worker", n)
+	}
+	if n := fallbackCalls.Load(); n != int64(len(groups)) {
+		t.Errorf("fallback model got %d requests, want %d", n, len(groups))
+	}
+	for _, group := range groups {
+		for i, idx := range group.Indices {
+			if want := "T:" + group.Texts[i]; results[idx] != want {
+				t.Errorf("cue %d = %q, want %q", idx, results[idx], want)
+			}
+		}
+	}
+	for _, model := range models {
+		if model != "fallback" {
+			t.Errorf("report.Model = %q, want fallback", model)
+		}
+	}
+}
+
+func TestTranslateGroupsStopsWhenAllModelsRejected(t *testing.T) {
+	unauthorized := func(messages []*schema.Message) (*schema.Message, error) {
+		time.Sleep(10 * time.Millisecond)
+		return nil, &APIError{Provider: ProviderOpenAI, StatusCode: http.StatusUnauthorized, Message: "invalid api key"}
+	}
+	var calls atomic.Int64
+	translator := newTestTranslator(func(messages []*schema.Message) (*schema.Message, error) {
+		calls.Add(1)
+		return unauthorized(messages)
+	}, 2)
+	fallback := &fakeChatModel{generate: unauthorized}
+	translator.fallbacks = []fallbackModel{{model: fallback, translateModel: fallback, name: "fallback"}}
+
+	_, err := translator.TranslateGroups(context.Background(), testGroups(10), "en", "zh", nil)
+	var fatal *FatalAPIError
+	if !errors.As(err, &fatal) || fatal.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("TranslateGroups error = %v, want *FatalAPIError with status 401", err)
+	}
+	if n := calls.Load(); n > 2 {
+		t.Errorf("sent %d requests after a 401, want at most one per worker", n)
+	}
+}
+
+func TestSummarizeContextFallsBackOnFatalAPIError(t *testing.T) {
+	translator := newTestTranslator(func(messages []*schema.Message) (*schema.Message, error) {
+		return nil, &APIError{Provider: ProviderOpenAI, StatusCode: http.StatusForbidden, Message: "model not allowed"}
+	}, 1)
+	fallback := &fakeChatModel{generate: func(messages []*schema.Message) (*schema.Message, error) {
+		return schema.AssistantMessage("summary from fallback", nil), nil
+	}}
+	translator.fallbacks = []fallbackModel{{model: fallback, translateModel: fallback, name: "fallback"}}
+
+	items := []*SubtitleItem{{Index: 1, Text: "Hello."}}
+	if err := translator.SummarizeContext(context.Background(), "movie.srt", items); err != nil {
+		t.Fatalf("SummarizeContext: %v", err)
+	}
+	if translator.context != "summary from fallback" {
+		t.Errorf("context = %q, want the fallback summary", translator.context)
+	}
+}
+
+func TestTranslateGroupsFallsBackOnTransientError(t *testing.T) {
+	translator := newTestTranslator(func(messages []*schema.Message) (*schema.Message, error) {
+		return nil, &APIError{Provider: ProviderOpenAI, StatusCode: http.StatusServiceUnavailable, Message: "overloaded"}
+	}, 1)
+	rejected := &fakeChatModel{generate: func(messages []*schema.Message) (*schema.Message, error) {
+		return nil, &APIError{Provider: ProviderOpenAI, StatusCode: http.StatusNotFound, Message: "model not found"}
+	}}
+	working := &fakeChatModel{generate: submitTranslations}
+	translator.fallbacks = []fallbackModel{
+		{model: rejected, translateModel: rejected, name: "rejected"},
+		{model: working, translateModel: working, name: "working"},
+	}
+	var events []Event
+	translator.events = func(e Event) { events = append(events, e) }
+
+	var report *GroupReport
+	groups := testGroups(1)
+	results, err := translator.TranslateGroups(context.Background(), groups, "en", "zh", func(group SubtitleGroup, translations []string, r *GroupReport) {
+		report = r
+	})
+	if err != nil {
+		t.Fatalf("TranslateGroups: %v", err)
+	}
+	for i, idx := range groups[0].Indices {
+		if want := "T:" + groups[0].Texts[i]; results[idx] != want {
+			t.Errorf("cue %d = %q, want %q", idx, results[idx], want)
+		}
+	}
+	// 备用模型拒绝请求时跳过它继续换用下一个模型，失败原因都记录在报告中
+	if report.Model != "working" || report.Error != "" {
+		t.Errorf("report model = %q, error = %q, want working and no error", report.Model, report.Error)
+	}
+	if len(report.ModelFailures) != 2 || !strings.HasPrefix(report.ModelFailures[0], "fake: ") || !strings.HasPrefix(report.ModelFailures[1], "rejected: ") {
+		t.Errorf("model failures = %q, want fake and rejected", report.ModelFailures)
+	}
+	var fallbacks []string
+	for _, e := range events {
+		if e.Type == EventModelFallback {
+			fallbacks = append(fallbacks, e.Model)
+			if e.Group == nil || *e.Group != groups[0].ID || e.TargetLang != "zh" || e.Reason == "" {
+				t.Errorf("fallback event = %+v", e)
+			}
+		}
+	}
+	if want := []string{"rejected", "working"}; !slices.Equal(fallbacks, want) {
+		t.Errorf("fallback events = %v, want %v", fallbacks, want)
+	}
+}
+
+func TestTranslateGroupsKeepsBestWhenFallbackRejected(t *testing.T) {
+	// 主模型只译出第一条，备用模型拒绝请求时仍采用主模型的译文
+	translator := newTestTranslator(func(messages []*schema.Message) (*schema.Message, error) {
+		resp, err := submitTranslations(messages)
+		if err != nil {
+			return nil, err
+		}
+		var req SubmitTranslationReq
+		if err := json.Unmarshal([]byte(resp.ToolCalls[0].Function.Arguments), &req); err != nil {
+			return nil, err
+		}
+		arguments, err := json.Marshal(SubmitTranslationReq{Translations: req.Translations[:1]})
+		if err != nil {
+			return nil, err
+		}
+		resp.ToolCalls[0].Function.Arguments = string(arguments)
+		return resp, nil
+	}, 1)
+	rejected := &fakeChatModel{generate: func(messages []*schema.Message) (*schema.Message, error) {
+		return nil, &APIError{Provider: ProviderOpenAI, StatusCode: http.StatusPaymentRequired, Message: "insufficient balance"}
+	}}
+	translator.fallbacks = []fallbackModel{{model: rejected, translateModel: rejected, name: "rejected"}}
+
+	var report *GroupReport
+	groups := testGroups(1)
+	results, err := translator.TranslateGroups(context.Background(), groups, "en", "zh", func(group SubtitleGroup, translations []string, r *GroupReport) {
+		report = r
+	})
+	if err != nil {
+		t.Fatalf("TranslateGroups: %v", err)
+	}
+	texts, indices := groups[0].Texts, groups[0].Indices
+	if results[indices[0]] != "T:"+texts[0] || results[indices[1]] != texts[1] {
+		t.Errorf("results = %q, want the primary model's partial translation", results)
+	}
+	if report.Model != "fake" || len(report.ModelFailures) != 2 || !strings.HasPrefix(report.ModelFailures[1], "rejected: ") {
+		t.Errorf("report model = %q, failures = %q, want fake with the fallback rejection recorded", report.Model, report.ModelFailures)
+	}
+}
+
+func TestTranslateGroupsDoesNotCacheInvalidTranslations(t *testing.T) {
+	var calls atomic.Int64
+	translator := newTestTranslator(func(messages []*schema.Message) (*schema.Message, error) {
+		calls.Add(1)
+		return submitTranslations(messages)
+	}, 1)
+	cache, err := NewTranslationCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	translator.cache = cache
+	// 译文没有使用术语表中的译法，三次提交都不通过校验
+	translator.SetGlossary("", &Glossary{Entries: []*GlossaryEntry{NewGlossaryEntry("group", "组", "")}})
+
+	for run := 1; run <= 2; run++ {
+		var valid []bool
+		_, err := translator.TranslateGroups(context.Background(), testGroups(1), "en", "zh", func(group SubtitleGroup, translations []string, report *GroupReport) {
+			valid = append(valid, report.valid)
+		})
+		if err != nil {
+			t.Fatalf("run %d: TranslateGroups: %v", run, err)
+		}
+		if want := int64(3 * run); calls.Load() != want {
+			t.Errorf("run %d: %d requests in total, want %d (invalid translations are not cached)", run, calls.Load(), want)
+		}
+		if !slices.Equal(valid, []bool{false}) {
+			t.Errorf("run %d: report.valid = %v, want [false]", run, valid)
+		}
+	}
+}
+
+func TestTranslateGroupsToleratesRequestErrors(t *testing.T) {
+	groups := testGroups(3)
+	translator := newTestTranslator(func(messages []*schema.Message) (*schema.Message, error) {
+		if groupTexts(messages)[0] == groups[1].Texts[0] {
+			return nil, &APIError{Provider: ProviderOpenAI, StatusCode: http.StatusBadRequest, Message: "maximum context length exceeded"}
+		}
+		return submitTranslations(messages)
+	}, 2)
+
+	// 400 与请求内容有关，只让当前分组失败
+	var mu sync.Mutex
+	var failed []int
+	results, err := translator.TranslateGroups(context.Background(), groups, "en", "zh", func(group SubtitleGroup, translations []string, report *GroupReport) {
+		if report.Error != "" {
+			mu.Lock()
+			failed = append(failed, group.ID)
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatalf("TranslateGroups: %v", err)
+	}
+	if !slices.Equal(failed, []int{1}) || results[groups[2].Indices[0]] != "T:"+groups[2].Texts[0] {
+		t.Errorf("failed groups = %v, results = %v, want only group 1 to fail", failed, results)
+	}
+}
+
+// cues 按 texts 生成字幕，每条 1 秒，gaps[i] 是第 i 条与第 i+1 条之间的间隔（默认 100ms）
+func cues(texts []string, gaps map[int]time.Duration) []*SubtitleItem {
+	var items []*SubtitleItem
+	var start time.Duration
+	for i, text := range texts {
+		items = append(items, &SubtitleItem{Index: i + 1, StartAt: start, EndAt: start + time.Second, Text: text})
+		gap, ok := gaps[i]
+		if !ok {
+			gap = 100 * time.Millisecond
+		}
+		start += time.Second + gap
+	}
+	return items
+}
+
+// groupIndices 返回每个分组包含的字幕序号
+func groupIndices(groups []SubtitleGroup) [][]int {
+	var indices [][]int
+	for _, group := range groups {
+		indices = append(indices, group.Indices)
+	}
+	return indices
+}
+
+func TestGroupSubtitlesSplit(t *testing.T) {
+	long := strings.Repeat("word ", 200)
+	tests := []struct {
+		name  string
+		texts []string
+		gaps  map[int]time.Duration
+		opts  GroupOptions
+		want  [][]int
+	}{
+		{
+			name:  "one cue per group",
+			texts: []string{"a", "b", "c"},
+			opts:  GroupOptions{MaxGapSeconds: 3, MaxCues: 1},
+			want:  [][]int{{0}, {1}, {2}},
+		},
+		{
+			// 只有一条字幕放得下时不再寻找句子边界
+			name:  "window of one",
+			texts: []string{"one", "two", "three."},
+			opts:  GroupOptions{MaxGapSeconds: 3, MaxTokens: 1},
+			want:  [][]int{{0}, {1}, {2}},
+		},
+		{
+			name:  "split after last sentence end in second half",
+			texts: []string{"a.", "b", "c.", "d", "e", "f", "g"},
+			opts:  GroupOptions{MaxGapSeconds: 3, MaxCues: 4},
+			want:  [][]int{{0, 1, 2}, {3, 4, 5, 6}},
+		},
+		{
+			// 句末标点只出现在前半段时不使用，改在后半段间隔最长处拆分
+			name:  "no sentence end in second half",
+			texts: []string{"a.", "b", "c", "d", "e", "f", "g", "h"},
+			gaps:  map[int]time.Duration{4: 2 * time.Second},
+			opts:  GroupOptions{MaxGapSeconds: 3, MaxCues: 6},
+			want:  [][]int{{0, 1, 2, 3, 4}, {5, 6, 7}},
+		},
+		{
+			name:  "no sentence end and equal gaps",
+			texts: []string{"a", "b", "c", "d", "e"},
+			opts:  GroupOptions{MaxGapSeconds: 3, MaxCues: 4},
+			want:  [][]int{{0, 1, 2, 3}, {4}},
+		},
+		{
+			// 超过上限的单条字幕单独成组，不会产生空组或死循环
+			name:  "single cue over budget",
+			texts: []string{"a.", long, "b."},
+			opts:  GroupOptions{MaxGapSeconds: 3, MaxTokens: 50},
+			want:  [][]int{{0}, {1}, {2}},
+		},
+		{
+			name:  "unlimited tokens",
+			texts: []string{"a.", long, "b."},
+			opts:  GroupOptions{MaxGapSeconds: 3, MaxTokens: -1},
+			want:  [][]int{{0, 1, 2}},
+		},
+		{
+			name:  "time gap",
+			texts: []string{"a", "b", "c"},
+			gaps:  map[int]time.Duration{0: 5 * time.Second},
+			opts:  GroupOptions{MaxGapSeconds: 3},
+			want:  [][]int{{0}, {1, 2}},
+		},
+	}
+	for _, tt := range tests {
+		got := groupIndices(GroupSubtitles(cues(tt.texts, tt.gaps), tt.opts))
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("%s: groups = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEndsSentence(t *testing.T) {
+	for text, want := range map[string]bool{
+		"Hello.":          true,
+		"Really?!":        true,
+		"好的。":             true,
+		"Wait…":           true,
+		`He said "go."`:   true,
+		"（笑）":             false,
+		"「行くぞ！」":          true,
+		"and then":        false,
+		"Mr":              false,
+		"":                false,
+		"   ":             false,
+		"♪ la la la ♪":    true,
+		"(thinking...)  ": true,
+	} {
+		if got := endsSentence(text); got != want {
+			t.Errorf("endsSentence(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestGroupOptionsModelDefault(t *testing.T) {
+	tests := []struct {
+		grouping GroupOptions
+		model    string
+		want     int
+	}{
+		{GroupOptions{}, "gpt-4o-2024-08-06", 2500},
+		{GroupOptions{}, "gpt-4o-mini", 2500},
+		{GroupOptions{}, "anthropic/claude-sonnet-4-5", 3000},
+		{GroupOptions{}, "qwen2.5:7b", defaultGroupMaxTokens},
+		{GroupOptions{MaxGapSeconds: 3, MaxCues: 40, MaxTokens: 800}, "gpt-4.1", 800},
+		{GroupOptions{MaxGapSeconds: 3, MaxCues: 40, MaxTokens: -1}, "gpt-4.1", -1},
+	}
+	for _, tt := range tests {
+		opts := groupOptions(AgentInput{Grouping: tt.grouping}, tt.model)
+		if opts.MaxTokens != tt.want {
+			t.Errorf("groupOptions(%+v, %s).MaxTokens = %d, want %d", tt.grouping, tt.model, opts.MaxTokens, tt.want)
+		}
+		if tt.grouping == (GroupOptions{}) && opts.MaxCues != DefaultGroupOptions.MaxCues {
+			t.Errorf("groupOptions(zero, %s).MaxCues = %d, want the default %d", tt.model, opts.MaxCues, DefaultGroupOptions.MaxCues)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 用量统计的阶段，翻译时的重试单独统计以便看出重试的额外开销
+const (
+	StageSummary        = "summary"
+	StageTerms          = "terms"
+	StageTranslate      = "translate"
+	StageTranslateRetry = "translate_retry"
+	StageReview         = "review"
+)
+
+// ModelPrice 是模型每百万 token 的价格（美元）
+type ModelPrice struct {
+	Input  float64 `yaml:"input" json:"input"`
+	Output float64 `yaml:"output" json:"output"`
+}
+
+// defaultModelPrices 是内置的价格表，可以在配置文件的 prices 中覆盖或补充
+var defaultModelPrices = map[string]ModelPrice{
+	"gpt-3.5-turbo": {Input: 0.50, Output: 1.50},
+	"gpt-4o":        {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.60},
+	"gpt-4.1":       {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":  {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":  {Input: 0.10, Output: 0.40},
+}
+
+// lookupModelPrice 查找模型价格，prices 优先于内置价格表。没有完全匹配时使用最长的前缀匹配，
+// 例如 gpt-4o-2024-08-06 使用 gpt-4o 的价格
+func lookupModelPrice(prices map[string]ModelPrice, model string) (ModelPrice, bool) {
+	table := maps.Clone(defaultModelPrices)
+	maps.Copy(table, prices)
+
+	if price, ok := table[model]; ok {
+		return price, true
+	}
+	best := ""
+	for name := range table {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return table[best], true
+}
+
+// StageUsage 是某个模型在某个阶段的用量
+type StageUsage struct {
+	Model            string  `json:"model"`
+	Stage            string  `json:"stage"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	// Priced 为 false 表示价格表中没有该模型，Cost 为 0
+	Priced bool `json:"priced"`
+}
+
+// UsageSummary 是一次运行的用量和费用汇总
+type UsageSummary struct {
+	Stages           []StageUsage `json:"stages"`
+	Requests         int          `json:"requests"`
+	PromptTokens     int          `json:"prompt_tokens"`
+	CompletionTokens int          `json:"completion_tokens"`
+	Cost             float64      `json:"cost"`
+	// RetryCost 是翻译重试产生的费用，已包含在 Cost 中
+	RetryCost float64 `json:"retry_cost"`
+}
+
+// UsageTracker 按模型和阶段累计模型返回的 token 用量，可以被多个 goroutine 并发调用
+type UsageTracker struct {
+	mu     sync.Mutex
+	stages map[string]*StageUsage
+	order  []string
+}
+
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{stages: make(map[string]*StageUsage)}
+}
+
+// Add 记录一次请求的用量，模型没有返回用量时只计入请求数
+func (u *UsageTracker) Add(model string, stage string, usage *schema.TokenUsage) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	key := model + "\x00" + stage
+	stats, ok := u.stages[key]
+	if !ok {
+		stats = &StageUsage{Model: model, Stage: stage}
+		u.stages[key] = stats
+		u.order = append(u.order, key)
+	}
+	stats.Requests++
+	if usage != nil {
+		stats.PromptTokens += usage.PromptTokens
+		stats.CompletionTokens += usage.CompletionTokens
+	}
+}
+
+// Tokens 返回目前为止所有请求消耗的 token 总数
+func (u *UsageTracker) Tokens() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	total := 0
+	for _, stats := range u.stages {
+		total += stats.PromptTokens + stats.CompletionTokens
+	}
+	return total
+}
+
+// Summary 按价格表计算各阶段的费用并汇总
+func (u *UsageTracker) Summary(prices map[string]ModelPrice) *UsageSummary {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	summary := &UsageSummary{}
+	for _, key := range u.order {
+		stats := *u.stages[key]
+		if price, ok := lookupModelPrice(prices, stats.Model); ok {
+			stats.Priced = true
+			stats.Cost = (float64(stats.PromptTokens)*price.Input + float64(stats.CompletionTokens)*price.Output) / 1e6
+		}
+		summary.Stages = append(summary.Stages, stats)
+		summary.Requests += stats.Requests
+		summary.PromptTokens += stats.PromptTokens
+		summary.CompletionTokens += stats.CompletionTokens
+		summary.Cost += stats.Cost
+		if stats.Stage == StageTranslateRetry {
+			summary.RetryCost += stats.Cost
+		}
+	}
+	return summary
+}
+
+func PrintUsageSummary(w io.Writer, summary *UsageSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tSTAGE\tREQUESTS\tPROMPT\tCOMPLETION\tCOST")
+	for _, stats := range summary.Stages {
+		cost := "n/a"
+		if stats.Priced {
+			cost = fmt.Sprintf("$%.4f", stats.Cost)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n", stats.Model, stats.Stage, stats.Requests, stats.PromptTokens, stats.CompletionTokens, cost)
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t%d\t%d\t%d\t$%.4f\n", summary.Requests, summary.PromptTokens, summary.CompletionTokens, summary.Cost)
+	tw.Flush()
+	if summary.RetryCost > 0 {
+		fmt.Fprintf(w, "retries cost $%.4f of the total\n", summary.RetryCost)
+	}
+}